@@ -0,0 +1,256 @@
+package xlog
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// OverflowPolicy is what AsyncWriter does when its queue is full.
+type OverflowPolicy int
+
+// Overflow policies.
+const (
+	OverflowBlock      OverflowPolicy = iota // wait until there is room in the queue.
+	OverflowDropNewest                       // drop the log being written.
+	OverflowDropOldest                       // drop the oldest log in the queue.
+)
+
+// ErrAsyncWriterClosed is returned by writing to a closed AsyncWriter.
+var ErrAsyncWriterClosed = errors.New("xlog: AsyncWriter is closed")
+
+// AsyncConfig is the config of AsyncWriter.
+type AsyncConfig struct {
+	QueueSize int // the max number of logs in the queue, 1024 by default.
+	Overflow  OverflowPolicy
+	// ReportInterval is the interval to report the number of dropped logs,
+	// 10 seconds by default.
+	ReportInterval time.Duration
+}
+
+// AsyncWriter is an io.Writer which puts the logs into a bounded queue, and
+// writes them to the underlying writer in a background goroutine.
+// When the queue is full, it blocks or drops logs according to the OverflowPolicy.
+//
+// If a Logger writes to it directly, e.g. by Config.Async, the number of
+// dropped logs is reported by the Logger periodically as a warning log, and
+// when the Logger is synced or closed. The reports are never dropped, and
+// don't take the room of the logs in the queue.
+type AsyncWriter struct {
+	w io.Writer
+	c AsyncConfig
+
+	wmu        sync.Mutex // serializes the writes to w.
+	mu         sync.Mutex
+	cond       *sync.Cond
+	queue      [][]byte // a ring buffer.
+	head       int
+	n          int
+	writing    bool
+	report     []byte // the pending reports of dropped logs, see asyncReportWriter.
+	reportAt   int    // the number of logs in the queue to write before report.
+	closed     bool
+	err        error // the last error of w.Write, returned by Flush.
+	dropped    uint64
+	reported   uint64
+	lastReport time.Time
+	done       chan struct{}
+}
+
+// NewAsyncWriter creates an AsyncWriter writing to w, and starts its background goroutine.
+func NewAsyncWriter(w io.Writer, c AsyncConfig) *AsyncWriter {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1024
+	}
+	if c.ReportInterval <= 0 {
+		c.ReportInterval = 10 * time.Second
+	}
+	aw := &AsyncWriter{
+		w:          w,
+		c:          c,
+		queue:      make([][]byte, c.QueueSize),
+		lastReport: time.Now(),
+		done:       make(chan struct{}),
+	}
+	aw.cond = sync.NewCond(&aw.mu)
+	go aw.run()
+	return aw
+}
+
+// Write copies p to the queue. It only returns an error if the writer is closed.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	return aw.write(p, aw.c.Overflow)
+}
+
+// write copies p to the queue, policy is used if the queue is full.
+func (aw *AsyncWriter) write(p []byte, policy OverflowPolicy) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	for !aw.closed && aw.n == len(aw.queue) {
+		switch policy {
+		case OverflowDropNewest:
+			aw.dropped++
+			return len(p), nil
+		case OverflowDropOldest:
+			aw.pop()
+			aw.dropped++
+		default:
+			aw.cond.Wait()
+		}
+	}
+	if aw.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+	aw.queue[(aw.head+aw.n)%len(aw.queue)] = b
+	aw.n++
+	aw.cond.Broadcast()
+	return len(p), nil
+}
+
+// writeSync waits for the queue to be written, and then writes p to the underlying writer directly.
+func (aw *AsyncWriter) writeSync(p []byte) (int, error) {
+	_ = aw.Flush()
+	aw.wmu.Lock()
+	defer aw.wmu.Unlock()
+	return aw.w.Write(p)
+}
+
+// Flush waits until the logs in the queue are written,
+// and returns the last error of the underlying writer since the last Flush.
+func (aw *AsyncWriter) Flush() error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	for aw.n > 0 || aw.report != nil || aw.writing {
+		aw.cond.Wait()
+	}
+	err := aw.err
+	aw.err = nil
+	return err
+}
+
+// Sync flushes the queue, and syncs the underlying writer if it has the method `Sync() error`.
+func (aw *AsyncWriter) Sync() error {
+	err := aw.Flush()
+	if err2 := syncWriter(aw.w); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Close writes the logs in the queue and stops the background goroutine.
+// The underlying writer is not closed.
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return nil
+	}
+	aw.closed = true
+	aw.cond.Broadcast()
+	aw.mu.Unlock()
+
+	<-aw.done
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	err := aw.err
+	aw.err = nil
+	return err
+}
+
+// Dropped returns the total number of dropped logs.
+func (aw *AsyncWriter) Dropped() uint64 {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	return aw.dropped
+}
+
+// takeDropped returns the number of logs dropped since the last report,
+// if the ReportInterval has elapsed since then or force is set.
+func (aw *AsyncWriter) takeDropped(now time.Time, force bool) uint64 {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	if aw.dropped == aw.reported || (!force && now.Sub(aw.lastReport) < aw.c.ReportInterval) {
+		return 0
+	}
+	n := aw.dropped - aw.reported
+	aw.reported = aw.dropped
+	aw.lastReport = now
+	return n
+}
+
+// pop removes the oldest log from the queue, aw.mu must be held.
+func (aw *AsyncWriter) pop() []byte {
+	b := aw.queue[aw.head]
+	aw.queue[aw.head] = nil
+	aw.head = (aw.head + 1) % len(aw.queue)
+	aw.n--
+	if aw.reportAt > 0 {
+		aw.reportAt--
+	}
+	return b
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	for {
+		for aw.n == 0 && aw.report == nil && !aw.closed {
+			aw.cond.Wait()
+		}
+		if aw.n == 0 && aw.report == nil {
+			return // closed, and all logs are written.
+		}
+		var b []byte
+		if aw.report != nil && aw.reportAt == 0 {
+			b, aw.report = aw.report, nil
+		} else {
+			b = aw.pop()
+		}
+		aw.writing = true
+		aw.cond.Broadcast() // wake up the blocked writers.
+		aw.mu.Unlock()
+
+		aw.wmu.Lock()
+		_, err := aw.w.Write(b)
+		aw.wmu.Unlock()
+
+		aw.mu.Lock()
+		if err != nil {
+			aw.err = err
+		}
+		aw.writing = false
+		aw.cond.Broadcast() // wake up Flush.
+	}
+}
+
+// asyncSyncWriter writes to the AsyncWriter by writeSync.
+type asyncSyncWriter AsyncWriter
+
+func (w *asyncSyncWriter) Write(p []byte) (int, error) {
+	return (*AsyncWriter)(w).writeSync(p)
+}
+
+// asyncReportWriter writes the report of dropped logs to the AsyncWriter.
+// The report is kept out of the queue, so it is never dropped or blocked, and
+// doesn't drop the logs in the queue. It is written after the logs in the queue.
+type asyncReportWriter AsyncWriter
+
+func (w *asyncReportWriter) Write(p []byte) (int, error) {
+	aw := (*AsyncWriter)(w)
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+	if aw.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+	if aw.report == nil {
+		aw.reportAt = aw.n
+	}
+	aw.report = append(aw.report, p...)
+	aw.cond.Broadcast()
+	return len(p), nil
+}
@@ -0,0 +1,207 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter blocks Write until it is released.
+type blockingWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{release: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// waitWriting waits until the background goroutine of aw is writing.
+func waitWriting(aw *AsyncWriter) {
+	for {
+		aw.mu.Lock()
+		writing := aw.writing
+		aw.mu.Unlock()
+		if writing {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	cases := []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{OverflowDropNewest, "1\n2\n3\n"},
+		{OverflowDropOldest, "1\n4\n5\n"},
+	}
+	for _, c := range cases {
+		bw := newBlockingWriter()
+		aw := NewAsyncWriter(bw, AsyncConfig{QueueSize: 2, Overflow: c.policy})
+		aw.Write([]byte("1\n"))
+		waitWriting(aw) // "1" is taken by the background goroutine.
+		for _, s := range []string{"2\n", "3\n", "4\n", "5\n"} {
+			if n, err := aw.Write([]byte(s)); n != 2 || err != nil {
+				t.Fatalf("write: %d, %v", n, err)
+			}
+		}
+		close(bw.release)
+		if err := aw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if bw.String() != c.want {
+			t.Errorf("policy %d: got %q, want %q", c.policy, bw.String(), c.want)
+		}
+		if aw.Dropped() != 2 {
+			t.Errorf("policy %d: dropped %d", c.policy, aw.Dropped())
+		}
+		if _, err := aw.Write([]byte("6\n")); err != ErrAsyncWriterClosed {
+			t.Errorf("write after close: %v", err)
+		}
+	}
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	bw := newBlockingWriter()
+	aw := NewAsyncWriter(bw, AsyncConfig{QueueSize: 1})
+	aw.Write([]byte("1\n"))
+	waitWriting(aw)
+	aw.Write([]byte("2\n"))
+
+	written := make(chan struct{})
+	go func() {
+		aw.Write([]byte("3\n"))
+		close(written)
+	}()
+	select {
+	case <-written:
+		t.Fatal("Write should block when the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(bw.release)
+	<-written
+	aw.Flush()
+	if bw.String() != "1\n2\n3\n" || aw.Dropped() != 0 {
+		t.Fatalf("got %q, dropped %d", bw.String(), aw.Dropped())
+	}
+	aw.Close()
+}
+
+func TestAsyncLogger(t *testing.T) {
+	osExit = func(code int) {}
+	defer func() {
+		osExit = os.Exit
+	}()
+
+	defer func(f func(time.Duration) (<-chan time.Time, func())) { newTicker = f }(newTicker)
+	newTicker = func(time.Duration) (<-chan time.Time, func()) { return nil, func() {} }
+
+	bw := newBlockingWriter()
+	l := NewWithWriter(bw, &Config{Async: &AsyncConfig{QueueSize: 1, Overflow: OverflowDropNewest, ReportInterval: time.Nanosecond}})
+	aw := l.(*logger).sinks[0].async
+	l.Error("1")
+	waitWriting(aw)
+	l.Error("2")
+	l.Error("3") // dropped.
+	l.Error("4") // reports "3" is dropped after "2", and "4" is dropped.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(bw.release)
+	}()
+	l.Fatal("5") // reports "4" is dropped, and waits for the queue to be written.
+
+	want := "[ERRO]1\n[ERRO]2\n" +
+		"[WARN]xlog: logs are dropped by AsyncWriter dropped=1\n" +
+		"[WARN]xlog: logs are dropped by AsyncWriter dropped=1\n" +
+		"[FATA]5\n"
+	if got := bw.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if aw.Dropped() != 2 {
+		t.Fatalf("dropped %d", aw.Dropped())
+	}
+}
+
+func TestAsyncLoggerReport(t *testing.T) {
+	defer func(f func(time.Duration) (<-chan time.Time, func())) { newTicker = f }(newTicker)
+	tick := make(chan time.Time)
+	newTicker = func(time.Duration) (<-chan time.Time, func()) { return tick, func() {} }
+
+	bw := newBlockingWriter()
+	l := NewWithWriter(bw, &Config{Async: &AsyncConfig{QueueSize: 1, Overflow: OverflowDropNewest, ReportInterval: time.Hour}})
+	aw := l.(*logger).sinks[0].async
+	l.Error("1")
+	waitWriting(aw)
+	l.Error("2")
+	l.Error("3")                      // dropped.
+	l.Error("4")                      // dropped, the ReportInterval has not elapsed.
+	tick <- time.Now().Add(time.Hour) // reports "3" and "4" are dropped, without dropping "2".
+	tick <- time.Now().Add(time.Hour) // nothing to report.
+	l.Error("5")                      // dropped.
+	close(bw.release)
+	if err := l.Sync(); err != nil { // reports "5" is dropped.
+		t.Fatal(err)
+	}
+	l.Error("6")
+	l.Error("7")
+	l.Error("8")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(bw.String(), "\n"), "\n")
+	want := []string{
+		"[ERRO]1",
+		"[ERRO]2",
+		"[WARN]xlog: logs are dropped by AsyncWriter dropped=2",
+		"[WARN]xlog: logs are dropped by AsyncWriter dropped=1",
+		"[ERRO]6",
+	}
+	if len(lines) < len(want) || strings.Join(lines[:len(want)], "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got %q, want prefix %q", lines, want)
+	}
+	// "7" and "8" may be dropped, the drops before Close are always reported.
+	var written, reported uint64
+	for _, line := range lines[len(want):] {
+		var n uint64
+		if _, err := fmt.Sscanf(line, "[WARN]xlog: logs are dropped by AsyncWriter dropped=%d", &n); err == nil {
+			reported += n
+		} else {
+			written++
+		}
+	}
+	if written+reported != 2 || aw.Dropped() != 3+reported {
+		t.Fatalf("written %d, reported %d, dropped %d: %q", written, reported, aw.Dropped(), lines)
+	}
+}
+
+func BenchmarkAsyncWriter(b *testing.B) {
+	l := NewWithWriter(discardWriter{}, &Config{Flag: LstdFlags, Async: &AsyncConfig{}})
+	for i := 0; i < b.N; i++ {
+		l.Println("test")
+	}
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
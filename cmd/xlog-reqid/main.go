@@ -0,0 +1,97 @@
+// Command xlog-reqid decodes the request ids generated by the built-in
+// generators of xlog, e.g. the ids pasted from tickets:
+//
+//	$ xlog-reqid AQAAAAIAAAAAAAAAAwAA
+//	AQAAAAIAAAAAAAAAAwAA type=counter pid=1 time=1970-01-01T00:00:00.000000002Z counter=3
+//
+// The ids are read from the arguments, or from the standard input, one per line,
+// if there is no argument. Use -host to tell which of the hosts generated the ids
+// of HostReqIDGen. The ids which can't be decoded are reported to the standard
+// error, and the exit code is 1.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chen-zyc/xlog"
+)
+
+func main() {
+	hosts := flag.String("host", "", "comma-separated host names to match the ids of HostReqIDGen")
+	utc := flag.Bool("utc", false, "print the time in UTC")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [reqid ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	d := decoder{w: os.Stdout, errw: os.Stderr, utc: *utc, hosts: map[string]string{}}
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			d.hosts[xlog.HostHash(h)] = h
+		}
+	}
+	if flag.NArg() > 0 {
+		for _, id := range flag.Args() {
+			d.decode(id)
+		}
+	} else {
+		s := bufio.NewScanner(os.Stdin)
+		for s.Scan() {
+			if id := strings.TrimSpace(s.Text()); id != "" {
+				d.decode(id)
+			}
+		}
+		if err := s.Err(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			d.failed = true
+		}
+	}
+	if d.failed {
+		os.Exit(1)
+	}
+}
+
+type decoder struct {
+	w      io.Writer // the decoded ids.
+	errw   io.Writer // the errors of the ids which can't be decoded.
+	utc    bool
+	hosts  map[string]string // hash -> host name
+	failed bool
+}
+
+func (d *decoder) decode(id string) {
+	info, err := xlog.ParseReqID(id)
+	if err != nil {
+		fmt.Fprintln(d.errw, err)
+		d.failed = true
+		return
+	}
+	line := fmt.Sprintf("%s type=%s", id, info.Type)
+	if info.PID != 0 {
+		line += fmt.Sprintf(" pid=%d", info.PID)
+	}
+	if !info.Time.IsZero() {
+		t := info.Time
+		if d.utc {
+			t = t.UTC()
+		}
+		line += " time=" + t.Format(time.RFC3339Nano)
+	}
+	if info.Type == xlog.ReqIDCounter || info.Type == xlog.ReqIDHost {
+		line += fmt.Sprintf(" counter=%d", info.Counter)
+	}
+	if info.Host != "" {
+		line += " host=" + info.Host
+		if name, ok := d.hosts[info.Host]; ok {
+			line += "(" + name + ")"
+		}
+	}
+	fmt.Fprintln(d.w, line)
+}
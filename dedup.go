@@ -0,0 +1,97 @@
+package xlog
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DedupConfig is the config of NewDedup.
+type DedupConfig struct {
+	// Window is the max duration of collapsing a message, 10 seconds by default.
+	// After it, the repeated count is reported and the message is written again.
+	Window time.Duration
+	// ByReqID makes the logs of different request ids not identical.
+	ByReqID bool
+	Now     func() time.Time // returns the current time, time.Now by default.
+}
+
+// NewDedup wraps l with a deduplicator, which collapses the consecutive identical
+// logs, i.e. with the same level and message, and then writes the repeated count
+// at the level of the collapsed logs like syslog:
+//
+//	[ERRO]dial tcp 10.0.0.1:3306: connect: connection refused
+//	[ERRO]last message repeated 523 times
+//
+// The count is written when a different log is written, the Window is over, or
+// the Logger is synced or closed. The Fatal and Panic logs are never collapsed.
+// The fields are not compared, and the state is shared by the Loggers derived
+// from the returned one by With.
+//
+// If l is nil, the default logger is wrapped. If l is a ReqLogger created by
+// NewReqLogger, the returned Logger is a ReqLogger too.
+func NewDedup(l Logger, c DedupConfig) Logger {
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return newFilterLogger(l, &dedup{c: c, repeated: -1})
+}
+
+type dedupKey struct {
+	lvl   Level
+	msg   string
+	reqID string // only set if ByReqID is set.
+}
+
+type dedup struct {
+	c DedupConfig
+
+	mu        sync.Mutex
+	last      dedupKey
+	lastReqID string
+	start     time.Time // the time when the last message is written.
+	repeated  int       // -1 if the next log should be written anyway.
+}
+
+func (d *dedup) filter(l Logger, calldepth int, lvl Level, reqID, msg string) bool {
+	key := dedupKey{lvl: lvl, msg: msg}
+	if d.c.ByReqID {
+		key.reqID = reqID
+	}
+	now := d.c.Now()
+
+	d.mu.Lock()
+	if d.repeated >= 0 && key == d.last && now.Sub(d.start) < d.c.Window {
+		d.repeated++
+		d.lastReqID = reqID
+		d.mu.Unlock()
+		return false
+	}
+	lastLvl, lastReqID, repeated := d.last.lvl, d.lastReqID, d.repeated
+	d.last, d.lastReqID, d.start, d.repeated = key, reqID, now, 0
+	d.mu.Unlock()
+
+	d.report(l, calldepth+1, lastLvl, lastReqID, repeated)
+	return true
+}
+
+func (d *dedup) flush(l Logger) {
+	d.mu.Lock()
+	lastLvl, lastReqID, repeated := d.last.lvl, d.lastReqID, d.repeated
+	d.repeated = -1
+	d.mu.Unlock()
+
+	d.report(l, 3, lastLvl, lastReqID, repeated)
+}
+
+func (d *dedup) close() {}
+
+func (d *dedup) report(l Logger, calldepth int, lvl Level, reqID string, repeated int) {
+	if repeated <= 0 {
+		return
+	}
+	_ = l.Output(lvl, calldepth+1, reqID, "last message repeated "+strconv.Itoa(repeated)+" times")
+}
@@ -0,0 +1,87 @@
+package xlog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDedup(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)}
+	l := NewDedup(NewWithWriter(&buf, nil), DedupConfig{Window: time.Minute, Now: clock.Now})
+	for i := 0; i < 524; i++ {
+		l.Errorf("connect: %s", "connection refused")
+	}
+	l.Warn("connect: connection refused") // a different level.
+	l.Warn("connect: connection refused")
+	l.Info("recovered")
+	expect := "[ERRO]connect: connection refused\n" +
+		"[ERRO]last message repeated 523 times\n" +
+		"[WARN]connect: connection refused\n" +
+		"[WARN]last message repeated 1 times\n" +
+		"[INFO]recovered\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+
+	// the count is reported when the window is over.
+	buf.Reset()
+	l.Info("recovered")
+	clock.Set(clock.Now().Add(30 * time.Second))
+	l.Info("recovered")
+	clock.Set(clock.Now().Add(30 * time.Second))
+	l.Info("recovered")
+	l.Info("recovered")
+	expect = "[INFO]last message repeated 2 times\n[INFO]recovered\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+
+	// Sync reports the count, and the next log is written.
+	buf.Reset()
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("recovered")
+	expect = "[INFO]last message repeated 1 times\n[INFO]recovered\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
+
+func TestDedupReqID(t *testing.T) {
+	var buf bytes.Buffer
+	base := NewWithWriter(&buf, nil)
+	for _, byReqID := range []bool{false, true} {
+		buf.Reset()
+		l := NewDedup(base, DedupConfig{ByReqID: byReqID})
+		a := NewReqLogger(l, ReqConfig{ReqID: "a"})
+		b := NewReqLogger(l, ReqConfig{ReqID: "b"})
+		a.Error("failed")
+		b.Error("failed")
+		b.Error("failed")
+		a.Info("done")
+
+		expect := "[ERRO][a]failed\n[ERRO][b]last message repeated 2 times\n[INFO][a]done\n"
+		if byReqID {
+			expect = "[ERRO][a]failed\n[ERRO][b]failed\n[ERRO][b]last message repeated 1 times\n[INFO][a]done\n"
+		}
+		if got := buf.String(); got != expect {
+			t.Errorf("ByReqID %v: expect %q, got %q", byReqID, expect, got)
+		}
+	}
+}
+
+func TestDedupWrapReqLogger(t *testing.T) {
+	var buf bytes.Buffer
+	rl := NewReqLogger(NewWithWriter(&buf, &Config{Flag: Lshortfile}), ReqConfig{ReqID: "id"})
+	l := NewDedup(rl, DedupConfig{})
+	l.Error("failed")
+	l.Error("failed")
+	l.Error("ok")
+	expect := "[ERRO][id]dedup_test.go:80: failed\n[ERRO][id]dedup_test.go:82: last message repeated 1 times\n[ERRO][id]dedup_test.go:82: ok\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
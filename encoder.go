@@ -0,0 +1,102 @@
+package xlog
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is a log entry passed to the Encoder.
+type Entry struct {
+	Time        time.Time
+	Level       Level
+	Flag        int // the Config.Flag of the Logger.
+	Prefix      string
+	ReqID       string
+	TraceID     string // the hex trace id, only set if the ReqLogger has a TraceContext.
+	SpanID      string // the hex span id, only set with TraceID.
+	File        string // only set if Lshortfile or Llongfile is set.
+	Line        int
+	Message     string // without the trailing newline.
+	Fields      []Field
+	ForceColors bool
+
+	tf *timeFormatter
+}
+
+var entryPool = sync.Pool{New: func() interface{} { return new(Entry) }}
+
+// Encoder encodes an Entry to bytes.
+// It is called concurrently, and must not retain the Entry.
+type Encoder interface {
+	// Encode appends the encoded e, including the trailing newline, to buf.
+	Encode(buf *[]byte, e *Entry)
+}
+
+// TextEncoder is the default Encoder, it writes the log as:
+//
+//	prefix date time [LEVL][reqID][traceID/spanID]file:line: message key=value
+type TextEncoder struct{}
+
+// Encode implements Encoder.
+func (TextEncoder) Encode(buf *[]byte, e *Entry) {
+	// add color to header.
+	if e.ForceColors {
+		*buf = append(*buf, e.Level.Color()...)
+	}
+	formatHeader(buf, e)
+	// clear color.
+	if e.ForceColors {
+		*buf = append(*buf, "\x1b[0m"...)
+	}
+	*buf = append(*buf, e.Message...)
+	appendFields(buf, e.Fields)
+	*buf = append(*buf, '\n')
+}
+
+// formatHeader writes log header to buf in following order:
+//   - e.Prefix (if it's not blank),
+//   - date and/or time (if corresponding flags or Config.TimeFormat are provided),
+//   - level
+//   - reqID
+//   - traceID/spanID (if the ReqLogger has a TraceContext)
+//   - file and line number (if corresponding flags are provided).
+func formatHeader(buf *[]byte, e *Entry) {
+	*buf = append(*buf, e.Prefix...)
+	if e.HasTime() {
+		e.AppendTime(buf)
+		*buf = append(*buf, ' ')
+	}
+
+	// log level
+	*buf = append(*buf, e.Level.LogStr()...)
+
+	// request id
+	if e.ReqID != "" {
+		*buf = append(*buf, '[')
+		*buf = append(*buf, e.ReqID...)
+		*buf = append(*buf, ']')
+	}
+	if e.TraceID != "" {
+		*buf = append(*buf, '[')
+		*buf = append(*buf, e.TraceID...)
+		*buf = append(*buf, '/')
+		*buf = append(*buf, e.SpanID...)
+		*buf = append(*buf, ']')
+	}
+
+	if e.Flag&(Lshortfile|Llongfile) != 0 {
+		appendCaller(buf, e)
+		*buf = append(*buf, ": "...)
+	}
+}
+
+// appendCaller writes file:line to buf, the file is shortened if Lshortfile is set.
+func appendCaller(buf *[]byte, e *Entry) {
+	file := e.File
+	if e.Flag&Lshortfile != 0 {
+		file = shortFile(file)
+	}
+	*buf = append(*buf, file...)
+	*buf = append(*buf, ':')
+	itoa(buf, e.Line, -1)
+}
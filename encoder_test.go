@@ -0,0 +1,45 @@
+package xlog
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testEncoder struct{}
+
+func (testEncoder) Encode(buf *[]byte, e *Entry) {
+	*buf = append(*buf, e.Level.String()...)
+	*buf = append(*buf, '|')
+	*buf = append(*buf, e.ReqID...)
+	*buf = append(*buf, '|')
+	*buf = append(*buf, e.Message...)
+	for _, f := range e.Fields {
+		*buf = append(*buf, '|')
+		*buf = append(*buf, f.Key...)
+	}
+	*buf = append(*buf, '\n')
+}
+
+func TestCustomEncoder(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Encoder: testEncoder{}})
+	rl := NewReqLogger(l.With("k", 1), ReqConfig{ReqID: "reqid"})
+	rl.Infoln("hello")
+	l.Error("world")
+
+	expected := "info|reqid|hello|k\nerror||world\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+}
+
+func TestTextEncoderColors(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Prefix: "P ", ForceColors: true})
+	l.Warn("hello")
+
+	expected := ColorWarn + "P [WARN]\x1b[0mhello\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+}
@@ -0,0 +1,172 @@
+package xlog
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// badKey is the key used for a value without a key in With.
+const badKey = "!BADKEY"
+
+// Field is a key/value pair attached to the log.
+// If the Value is a []Field, it is a group of fields, which is written as a
+// nested object by JSONEncoder, and as "key.subkey=value" pairs by the others.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields is the map form of fields, see Logger.WithFields.
+type Fields map[string]interface{}
+
+// sorted returns the fields sorted by key, so that the output is stable.
+func (fs Fields) sorted() []Field {
+	if len(fs) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, len(fs))
+	for k, v := range fs {
+		fields = append(fields, Field{Key: k, Value: v})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+	return fields
+}
+
+// keyvalsToFields converts the alternating keys and values to fields.
+// A key which is not a string is converted by fmt.Sprint, and a value without a key uses the key "!BADKEY".
+func keyvalsToFields(keyvals []interface{}) []Field {
+	if len(keyvals) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		if i == len(keyvals)-1 {
+			fields = append(fields, Field{Key: badKey, Value: keyvals[i]})
+			break
+		}
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		fields = append(fields, Field{Key: key, Value: keyvals[i+1]})
+	}
+	return fields
+}
+
+// appendFields writes fields to buf as " key=value" pairs.
+func appendFields(buf *[]byte, fields []Field) {
+	appendGroupFields(buf, "", fields)
+}
+
+// appendGroupFields writes fields to buf as " key=value" pairs, prefix is
+// prepended to the keys, and the groups are flattened.
+func appendGroupFields(buf *[]byte, prefix string, fields []Field) {
+	for _, f := range fields {
+		if group, ok := f.Value.([]Field); ok {
+			appendGroupFields(buf, prefix+f.Key+".", group)
+			continue
+		}
+		*buf = append(*buf, ' ')
+		appendTextString(buf, prefix+f.Key)
+		*buf = append(*buf, '=')
+		appendTextValue(buf, f.Value)
+	}
+}
+
+// appendTextValue writes v to buf, quoting it if necessary.
+func appendTextValue(buf *[]byte, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		appendTextString(buf, v)
+	case int:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case int64:
+		*buf = strconv.AppendInt(*buf, v, 10)
+	case int32:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case uint:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case uint64:
+		*buf = strconv.AppendUint(*buf, v, 10)
+	case uint32:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case float64:
+		*buf = strconv.AppendFloat(*buf, v, 'g', -1, 64)
+	case float32:
+		*buf = strconv.AppendFloat(*buf, float64(v), 'g', -1, 32)
+	case bool:
+		*buf = strconv.AppendBool(*buf, v)
+	case nil:
+		*buf = append(*buf, "<nil>"...)
+	case error:
+		appendTextString(buf, errorString(v))
+	case fmt.Stringer:
+		appendTextString(buf, stringerString(v))
+	default:
+		appendTextString(buf, fmt.Sprint(v))
+	}
+}
+
+// errorString returns v.Error(), the panic of it is caught like fmt, see catchPanic.
+func errorString(v error) (s string) {
+	defer catchPanic(v, "Error", &s)
+	return v.Error()
+}
+
+// stringerString returns v.String(), the panic of it is caught like fmt, see catchPanic.
+func stringerString(v fmt.Stringer) (s string) {
+	defer catchPanic(v, "String", &s)
+	return v.String()
+}
+
+// catchPanic recovers the panic of the method of v, and sets s to "<nil>" if v
+// is a nil pointer, e.g. a typed-nil error, or the message of the panic like fmt.
+func catchPanic(v interface{}, method string, s *string) {
+	if err := recover(); err != nil {
+		if isNilPointer(v) {
+			*s = "<nil>"
+			return
+		}
+		*s = fmt.Sprintf("!PANIC=%s method: %v", method, err)
+	}
+}
+
+// isNilPointer reports whether v is a nil pointer.
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// appendTextString writes s to buf, it is quoted if it is empty or contains spaces, '=', '"' or control characters.
+func appendTextString(buf *[]byte, s string) {
+	if needsQuote(s) {
+		*buf = strconv.AppendQuote(*buf, s)
+		return
+	}
+	*buf = append(*buf, s...)
+}
+
+func needsQuote(s string) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return true
+		}
+		i += size
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Prefix: "TEST: "})
+	l1 := l.With("user", "tom", "order", 42)
+	l2 := l1.With("err", errors.New("not found"), "msg", "a b", "empty", "")
+	l1.Infoln("hello")
+	l2.Info("world")
+	l.With("odd").Info("bad")
+	l.WithFields(Fields{"b": 2, "a": 1.5}).Info("map")
+	l.Info("plain")
+
+	expected := "TEST: [INFO]hello user=tom order=42\n"
+	expected += `TEST: [INFO]world user=tom order=42 err="not found" msg="a b" empty=""` + "\n"
+	expected += "TEST: [INFO]bad !BADKEY=odd\n"
+	expected += "TEST: [INFO]map a=1.5 b=2\n"
+	expected += "TEST: [INFO]plain\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+}
+
+func TestReqLoggerWith(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, nil)
+	rl := NewReqLogger(l, ReqConfig{ReqID: "reqid", Level: LevelDebug})
+	rl2, ok := rl.With("k", "v").(ReqLogger)
+	if !ok {
+		t.Fatal("With of ReqLogger should return a ReqLogger")
+	}
+	rl2.Debug("hello")
+	rl.Debug("world")
+
+	expected := "[DEBU][reqid]hello k=v\n[DEBU][reqid]world\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+}
+
+func TestGroupFields(t *testing.T) {
+	group := []Field{{Key: "a", Value: 1}, {Key: "h", Value: []Field{{Key: "b", Value: "x y"}}}}
+	fields := []Field{{Key: "g", Value: group}, {Key: "k", Value: true}}
+	for _, c := range []struct {
+		enc    Encoder
+		expect string
+	}{
+		{TextEncoder{}, `[INFO]msg g.a=1 g.h.b="x y" k=true` + "\n"},
+		{LogfmtEncoder{}, `level=info msg=msg g.a=1 g.h.b="x y" k=true` + "\n"},
+		{JSONEncoder{}, `{"level":"info","msg":"msg","g":{"a":1,"h":{"b":"x y"}},"k":true}` + "\n"},
+	} {
+		b := new(bytes.Buffer)
+		l := NewWithWriter(b, &Config{Encoder: c.enc})
+		l.With(fields[0].Key, fields[0].Value, fields[1].Key, fields[1].Value).Info("msg")
+		if b.String() != c.expect {
+			t.Errorf("%T: got %q, want %q", c.enc, b.String(), c.expect)
+		}
+	}
+}
+
+type nilError struct{ msg string }
+
+func (e *nilError) Error() string { return e.msg }
+
+type panicStringer struct{}
+
+func (panicStringer) String() string { panic("boom") }
+
+func TestNilPointerValues(t *testing.T) {
+	var e *nilError
+	var err error = e
+	for _, c := range []struct {
+		enc    Encoder
+		expect string
+	}{
+		{TextEncoder{}, "[INFO]x err=<nil> s=\"!PANIC=String method: boom\"\n"},
+		{LogfmtEncoder{}, "level=info msg=x err=<nil> s=\"!PANIC=String method: boom\"\n"},
+		{JSONEncoder{}, `{"level":"info","msg":"x","err":"<nil>","s":"!PANIC=String method: boom"}` + "\n"},
+	} {
+		b := new(bytes.Buffer)
+		NewWithWriter(b, &Config{Encoder: c.enc}).With("err", err, "s", panicStringer{}).Info("x")
+		if b.String() != c.expect {
+			t.Errorf("%T: got %q, want %q", c.enc, b.String(), c.expect)
+		}
+	}
+}
+
+func TestNeedsQuote(t *testing.T) {
+	cases := map[string]bool{
+		"":        true,
+		"abc":     false,
+		"中文":      false,
+		"a b":     true,
+		"a=b":     true,
+		`a"b`:     true,
+		"a\nb":    true,
+		"中 文":     true,
+		"\xffabc": true,
+	}
+	for s, want := range cases {
+		if got := needsQuote(s); got != want {
+			t.Errorf("needsQuote(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,587 @@
+package xlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupStyle is the way to name the rotated files.
+type BackupStyle int
+
+// Backup styles, taking "app.log" for example.
+const (
+	BackupTimestamp BackupStyle = iota // app.2026-10-17T12-00-00.000.log
+	BackupIndex                        // app.log.1, app.log.2, ..., app.log.1 is the newest.
+)
+
+// backupTimeFormat is the time format of BackupTimestamp.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotateInterval is the interval to rotate the file by time.
+type RotateInterval int
+
+// Rotate intervals, taking "app.log" for example.
+// The rotated files are named by the time of the interval, and BackupStyle is not used.
+// If the file is also rotated by MaxSize during the interval, an index is added to the name.
+const (
+	RotateNone   RotateInterval = iota
+	RotateHourly                // app.2026-10-17T15.log, app.2026-10-17T15.1.log, ...
+	RotateDaily                 // app.2026-10-17.log, app.2026-10-17.1.log, ...
+)
+
+func (ri RotateInterval) layout() string {
+	if ri == RotateHourly {
+		return "2006-01-02T15"
+	}
+	return "2006-01-02"
+}
+
+// start returns the start of the interval which t is in.
+func (ri RotateInterval) start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	if ri == RotateHourly {
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// next returns the start of the next interval of start.
+func (ri RotateInterval) next(start time.Time) time.Time {
+	if ri == RotateHourly {
+		return ri.start(start.Add(time.Hour))
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// FileConfig is the config of FileWriter.
+type FileConfig struct {
+	Filename    string
+	MaxSize     int64 // the max size in bytes of the file before it is rotated, 0 means no limit.
+	MaxBackups  int   // the max number of rotated files to keep, 0 means keep all.
+	BackupStyle BackupStyle
+	Perm        os.FileMode // the permission of the created files, 0644 by default.
+
+	RotateEvery RotateInterval // rotate the file hourly or daily, in addition to MaxSize.
+	// MaxAge is the max age of the rotated files, by their modification time. 0 means no limit.
+	MaxAge time.Duration
+	// Location is the time zone to decide when to rotate and to name the rotated files.
+	// If it is nil, the local time zone is used. Set it to time.UTC to match LUTC.
+	Location *time.Location
+	// Now returns the current time, time.Now by default. It can be replaced for testing.
+	Now func() time.Time
+	// Compress compresses the rotated files by gzip in the background.
+	Compress bool
+	// ErrorHandler handles the errors which are not returned by Write, e.g.
+	// failing to rotate the file. It prints the error to os.Stderr by default.
+	ErrorHandler func(error)
+}
+
+// FileWriter is an io.Writer which writes to a file, and rotates it when it
+// grows larger than MaxSize or every RotateEvery. The rotated files are renamed
+// by BackupStyle or RotateEvery. The rotated files exceeding MaxBackups or
+// MaxAge are removed, and the others are compressed if Compress is set, in
+// the background.
+// If the file is removed or renamed by others, it is created again.
+//
+// It is safe to be used concurrently.
+type FileWriter struct {
+	c FileConfig
+
+	mu        sync.Mutex
+	f         *os.File // nil if it failed to open.
+	size      int64
+	lastCheck time.Time
+	closed    bool
+	start     time.Time // the start of the current interval of RotateEvery.
+	end       time.Time // the end of the current interval of RotateEvery.
+	retryAt   time.Time // when to retry the rotation which failed in Write.
+
+	// backupMu serializes the renaming and removing of the rotated files
+	// between the writer and the mill goroutine.
+	backupMu sync.Mutex
+	millCh   chan struct{}
+	millDone chan struct{}
+}
+
+// checkInterval is the interval to check whether the file is removed.
+const checkInterval = time.Second
+
+// rotateRetryInterval is the interval to retry the rotation which failed in Write.
+const rotateRetryInterval = time.Minute
+
+var osRename = os.Rename // for testing
+
+// NewFileWriter opens or creates the file c.Filename and returns a FileWriter.
+func NewFileWriter(c FileConfig) (*FileWriter, error) {
+	if c.Filename == "" {
+		return nil, errors.New("xlog: FileConfig.Filename is empty")
+	}
+	if c.Perm == 0 {
+		c.Perm = 0644
+	}
+	if c.Location == nil {
+		c.Location = time.Local
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	if c.ErrorHandler == nil {
+		c.ErrorHandler = defaultErrorHandler
+	}
+	w := &FileWriter{
+		c:        c,
+		millCh:   make(chan struct{}, 1),
+		millDone: make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	go w.mill()
+	w.triggerMill()
+	return w, nil
+}
+
+func (w *FileWriter) now() time.Time {
+	return w.c.Now().In(w.c.Location)
+}
+
+// Write writes p to the file, rotating it first if the size will exceed MaxSize.
+// If the file can't be rotated, p is still written to it, the error is passed
+// to ErrorHandler, and the rotation is retried after a minute.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	} else {
+		w.reopenIfRemoved()
+	}
+	if (w.c.RotateEvery != RotateNone && !w.now().Before(w.end) ||
+		w.c.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.c.MaxSize) &&
+		!w.now().Before(w.retryAt) {
+		if err := w.rotate(); err != nil {
+			if w.f == nil {
+				return 0, err
+			}
+			w.retryAt = w.now().Add(rotateRetryInterval)
+			w.c.ErrorHandler(err)
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file immediately.
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.f == nil {
+		return w.open()
+	}
+	return w.rotate()
+}
+
+// Sync commits the content of the file to the disk.
+func (w *FileWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Sync()
+}
+
+// Close closes the file, and waits for the background removing to finish.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.f != nil {
+		err = w.f.Close()
+		w.f = nil
+	}
+	w.mu.Unlock()
+
+	close(w.millCh)
+	<-w.millDone
+	return err
+}
+
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.c.Filename), 0755); err != nil {
+		return fmt.Errorf("xlog: can't create the directory of the log file: %v", err)
+	}
+	f, err := os.OpenFile(w.c.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.c.Perm)
+	if err != nil {
+		return fmt.Errorf("xlog: can't open the log file: %v", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("xlog: can't stat the log file: %v", err)
+	}
+	w.f = f
+	w.size = fi.Size()
+	now := w.now()
+	w.lastCheck = now
+	if w.c.RotateEvery != RotateNone {
+		// The file may be written in a previous interval.
+		t := now
+		if w.size > 0 {
+			t = fi.ModTime().In(w.c.Location)
+		}
+		w.start = w.c.RotateEvery.start(t)
+		w.end = w.c.RotateEvery.next(w.start)
+	}
+	return nil
+}
+
+// reopenIfRemoved opens the file again if it is removed or renamed by others.
+// It checks at most once per checkInterval.
+func (w *FileWriter) reopenIfRemoved() {
+	now := w.now()
+	if now.Sub(w.lastCheck) < checkInterval {
+		return
+	}
+	w.lastCheck = now
+	fi, err := os.Stat(w.c.Filename)
+	if err == nil {
+		cur, err := w.f.Stat()
+		if err == nil && os.SameFile(fi, cur) {
+			return
+		}
+	}
+	old := w.f
+	if err := w.open(); err != nil {
+		return // keep writing to the old one.
+	}
+	old.Close()
+}
+
+// rotate renames the file to a backup and opens a new one.
+func (w *FileWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("xlog: can't close the log file: %v", err)
+	}
+	w.f = nil
+	w.backupMu.Lock()
+	var err error
+	switch {
+	case w.c.RotateEvery != RotateNone:
+		err = osRename(w.c.Filename, w.intervalBackupName(w.start))
+	case w.c.BackupStyle == BackupIndex:
+		err = w.shiftIndexBackups()
+	default:
+		err = osRename(w.c.Filename, w.timestampBackupName(w.now()))
+	}
+	w.backupMu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		// Keep writing to the file even if it can't be rotated.
+		if err2 := w.open(); err2 != nil {
+			return err2
+		}
+		return fmt.Errorf("xlog: can't rotate the log file: %v", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.triggerMill()
+	return nil
+}
+
+// splitFilename splits the filename to the name without extension and the extension.
+func (w *FileWriter) splitFilename() (base, ext string) {
+	ext = filepath.Ext(w.c.Filename)
+	return w.c.Filename[:len(w.c.Filename)-len(ext)], ext
+}
+
+func (w *FileWriter) timestampBackupName(t time.Time) string {
+	base, ext := w.splitFilename()
+	name := base + "." + t.Format(backupTimeFormat) + ext
+	for i := 1; backupExists(name); i++ {
+		name = base + "." + t.Format(backupTimeFormat) + "-" + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+func (w *FileWriter) intervalBackupName(start time.Time) string {
+	base, ext := w.splitFilename()
+	name := base + "." + start.Format(w.c.RotateEvery.layout()) + ext
+	for i := 1; backupExists(name); i++ {
+		name = base + "." + start.Format(w.c.RotateEvery.layout()) + "." + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+// shiftIndexBackups renames app.log.N to app.log.N+1, ..., app.log to app.log.1.
+func (w *FileWriter) shiftIndexBackups() error {
+	n := 0
+	for backupExists(w.indexBackupName(n + 1)) {
+		n++
+	}
+	for i := n; i > 0; i-- {
+		for _, ext := range []string{"", compressSuffix} {
+			err := osRename(w.indexBackupName(i)+ext, w.indexBackupName(i+1)+ext)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return osRename(w.c.Filename, w.indexBackupName(1))
+}
+
+func (w *FileWriter) indexBackupName(i int) string {
+	return w.c.Filename + "." + strconv.Itoa(i)
+}
+
+// compressSuffix is the suffix of the compressed files, which are written to
+// name+compressSuffix+tmpSuffix first and renamed when it is done.
+const (
+	compressSuffix = ".gz"
+	tmpSuffix      = ".tmp"
+)
+
+// backup is a rotated file.
+type backup struct {
+	name       string
+	order      int64 // the larger, the newer.
+	modTime    time.Time
+	compressed bool
+}
+
+// backups returns the rotated files, the newest first.
+func (w *FileWriter) backups() ([]backup, error) {
+	dir := filepath.Dir(w.c.Filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var bs []backup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := filepath.Join(dir, e.Name())
+		if strings.HasSuffix(name, compressSuffix+tmpSuffix) {
+			// Left by an interrupted compression, the mill goroutine is the only writer of it.
+			if _, ok := w.parseBackupName(strings.TrimSuffix(name, compressSuffix+tmpSuffix)); ok {
+				_ = os.Remove(name)
+			}
+			continue
+		}
+		compressed := strings.HasSuffix(name, compressSuffix)
+		order, ok := w.parseBackupName(strings.TrimSuffix(name, compressSuffix))
+		if !ok {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue // removed.
+		}
+		bs = append(bs, backup{name: name, order: order, modTime: fi.ModTime(), compressed: compressed})
+	}
+	sort.Slice(bs, func(i, j int) bool { return bs[i].order > bs[j].order })
+	return bs, nil
+}
+
+// parseBackupName returns the order of the backup, ok is false if name is not a backup.
+func (w *FileWriter) parseBackupName(name string) (order int64, ok bool) {
+	// The same order as rotate, BackupStyle is not used with RotateEvery.
+	if w.c.RotateEvery == RotateNone && w.c.BackupStyle == BackupIndex {
+		prefix := w.c.Filename + "."
+		if !strings.HasPrefix(name, prefix) {
+			return 0, false
+		}
+		i, err := strconv.Atoi(name[len(prefix):])
+		if err != nil || i <= 0 {
+			return 0, false
+		}
+		return -int64(i), true
+	}
+
+	base, ext := w.splitFilename()
+	if len(name) <= len(base)+1+len(ext) || !strings.HasPrefix(name, base+".") || !strings.HasSuffix(name, ext) {
+		return 0, false
+	}
+	ts := name[len(base)+1 : len(name)-len(ext)]
+	if w.c.RotateEvery != RotateNone {
+		return w.parseIntervalBackupName(ts)
+	}
+	var seq int64
+	if i := strings.LastIndexByte(ts, '-'); i == len(backupTimeFormat) {
+		n, err := strconv.Atoi(ts[i+1:])
+		if err != nil {
+			return 0, false
+		}
+		ts, seq = ts[:i], int64(n)
+	}
+	t, err := time.ParseInLocation(backupTimeFormat, ts, w.c.Location)
+	if err != nil {
+		return 0, false
+	}
+	return t.UnixNano()/1e6*1000 + seq, true
+}
+
+// parseIntervalBackupName parses the "2026-10-17" or "2026-10-17.1" part of the name.
+func (w *FileWriter) parseIntervalBackupName(ts string) (order int64, ok bool) {
+	var idx int64
+	layout := w.c.RotateEvery.layout()
+	if len(ts) > len(layout) {
+		if ts[len(layout)] != '.' {
+			return 0, false
+		}
+		n, err := strconv.Atoi(ts[len(layout)+1:])
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		ts, idx = ts[:len(layout)], int64(n)
+	}
+	t, err := time.ParseInLocation(layout, ts, w.c.Location)
+	if err != nil {
+		return 0, false
+	}
+	return t.Unix()*1e6 + idx, true
+}
+
+// triggerMill wakes up the mill goroutine, it doesn't block.
+func (w *FileWriter) triggerMill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default: // the mill goroutine will run anyway.
+	}
+}
+
+// mill removes the rotated files exceeding MaxBackups or MaxAge, and
+// compresses the others in the background.
+// The first run also compresses the files left by a previous process.
+func (w *FileWriter) mill() {
+	defer close(w.millDone)
+	for range w.millCh {
+		bs := w.removeOldBackups()
+		if !w.c.Compress {
+			continue
+		}
+		for _, b := range bs {
+			if !b.compressed {
+				_ = w.compress(b)
+			}
+		}
+	}
+}
+
+// removeOldBackups removes the rotated files exceeding MaxBackups or MaxAge,
+// and returns the remaining ones.
+func (w *FileWriter) removeOldBackups() []backup {
+	w.backupMu.Lock()
+	defer w.backupMu.Unlock()
+	bs, err := w.backups()
+	if err != nil {
+		return nil
+	}
+	if w.c.MaxBackups <= 0 && w.c.MaxAge <= 0 {
+		return bs
+	}
+	deadline := w.now().Add(-w.c.MaxAge)
+	remains := bs[:0]
+	for i, b := range bs {
+		if w.c.MaxBackups > 0 && i >= w.c.MaxBackups ||
+			w.c.MaxAge > 0 && b.modTime.Before(deadline) {
+			_ = os.Remove(b.name)
+			continue
+		}
+		remains = append(remains, b)
+	}
+	return remains
+}
+
+// compress compresses b to b.name+".gz" and removes b.
+// It writes to a temporary file and renames it at last, so that there is no
+// half-written ".gz" file if the process crashes.
+func (w *FileWriter) compress(b backup) error {
+	src, err := os.Open(b.name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	srcInfo, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst := b.name + compressSuffix
+	tmp := dst + tmpSuffix
+	if err := gzipTo(tmp, src, w.c.Perm); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	// Keep the modification time for MaxAge.
+	_ = os.Chtimes(tmp, srcInfo.ModTime(), srcInfo.ModTime())
+
+	w.backupMu.Lock()
+	defer w.backupMu.Unlock()
+	// The file may be renamed by shiftIndexBackups during the compression.
+	if fi, err := os.Stat(b.name); err != nil || !os.SameFile(fi, srcInfo) {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("xlog: %s is changed during compressing", b.name)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Remove(b.name)
+}
+
+// gzipTo writes the compressed content of src to the file name.
+func gzipTo(name string, src io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	if _, err := io.Copy(zw, src); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// backupExists reports whether the rotated file name exists, compressed or not.
+func backupExists(name string) bool {
+	return fileExists(name) || fileExists(name+compressSuffix)
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,410 @@
+package xlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// listDir returns the names of the files in dir, sorted.
+func listDir(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func readFile(t *testing.T, name string) string {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFileWriterIndex(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(FileConfig{Filename: name, MaxSize: 12, MaxBackups: 2, BackupStyle: BackupIndex})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewWithWriter(w, nil)
+	for _, s := range []string{"11111", "22222", "33333", "44444", "55555", "66666", "77777"} {
+		l.Print(s)
+	}
+	w.Close() // wait for removing.
+
+	if got := strings.Join(listDir(t, dir), ","); got != "app.log,app.log.1,app.log.2" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+	if got := readFile(t, name); got != "77777\n" {
+		t.Fatalf("app.log: %q", got)
+	}
+	if got := readFile(t, name+".1"); got != "55555\n66666\n" {
+		t.Fatalf("app.log.1: %q", got)
+	}
+	if got := readFile(t, name+".2"); got != "33333\n44444\n" {
+		t.Fatalf("app.log.2: %q", got)
+	}
+}
+
+func TestFileWriterTimestamp(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(FileConfig{Filename: name, MaxSize: 12, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"11111\n", "22222\n", "33333\n", "44444\n"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	w.Close() // wait for removing.
+
+	names := listDir(t, dir)
+	bs, _ := w.backups()
+	if len(names) != 3 || len(bs) != 2 {
+		t.Fatalf("unexpected files: %v", names)
+	}
+	if got := readFile(t, bs[0].name); got != "33333\n44444\n" {
+		t.Fatalf("newest backup %s: %q", bs[0].name, got)
+	}
+	if got := readFile(t, bs[1].name); got != "11111\n22222\n" {
+		t.Fatalf("oldest backup %s: %q", bs[1].name, got)
+	}
+	if got := readFile(t, name); got != "" {
+		t.Fatalf("app.log: %q", got)
+	}
+}
+
+func TestFileWriterRemoved(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(FileConfig{Filename: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	w.Write([]byte("1\n"))
+	if err := os.Remove(name); err != nil {
+		t.Fatal(err)
+	}
+	w.lastCheck = time.Time{}
+	w.Write([]byte("2\n"))
+	if got := readFile(t, name); got != "2\n" {
+		t.Fatalf("app.log: %q", got)
+	}
+
+	w.Close()
+	if _, err := w.Write([]byte("3\n")); err != os.ErrClosed {
+		t.Fatalf("write after close: %v", err)
+	}
+}
+
+// fakeClock is a clock which can be changed by tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func TestFileWriterDaily(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	clock := &fakeClock{now: time.Date(2026, 10, 16, 23, 59, 59, 0, time.UTC)}
+	w, err := NewFileWriter(FileConfig{
+		Filename:    name,
+		MaxSize:     12,
+		RotateEvery: RotateDaily,
+		Location:    time.UTC,
+		Now:         clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("11111\n"))
+	w.Write([]byte("22222\n"))
+	w.Write([]byte("33333\n")) // rotated by size.
+	clock.Set(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC))
+	w.Write([]byte("44444\n")) // rotated by time.
+	clock.Set(time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC))
+	w.Write([]byte("55555\n"))
+	w.Close()
+
+	files := map[string]string{
+		"app.2026-10-16.log":   "11111\n22222\n",
+		"app.2026-10-16.1.log": "33333\n",
+		"app.log":              "44444\n55555\n",
+	}
+	if names := listDir(t, dir); len(names) != len(files) {
+		t.Fatalf("unexpected files: %v", names)
+	}
+	for n, want := range files {
+		if got := readFile(t, filepath.Join(dir, n)); got != want {
+			t.Errorf("%s: got %q, want %q", n, got, want)
+		}
+	}
+
+	// restart on the next day, the file is rotated by its modification time.
+	mtime := time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	clock.Set(time.Date(2026, 10, 18, 8, 0, 0, 0, time.UTC))
+	w, err = NewFileWriter(FileConfig{Filename: name, RotateEvery: RotateDaily, Location: time.UTC, Now: clock.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("66666\n"))
+	w.Close()
+	if got := readFile(t, filepath.Join(dir, "app.2026-10-17.log")); got != "44444\n55555\n" {
+		t.Errorf("app.2026-10-17.log: %q", got)
+	}
+}
+
+func TestFileWriterHourlyLocation(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	loc := time.FixedZone("UTC+8", 8*3600)
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 1, 30, 0, 0, time.UTC)}
+	w, err := NewFileWriter(FileConfig{Filename: name, RotateEvery: RotateHourly, Location: loc, Now: clock.Now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("1\n"))
+	clock.Set(time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC))
+	w.Write([]byte("2\n"))
+	w.Close()
+	if got := readFile(t, filepath.Join(dir, "app.2026-10-17T09.log")); got != "1\n" {
+		t.Errorf("app.2026-10-17T09.log: %q", got)
+	}
+}
+
+func TestFileWriterMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for i, n := range []string{"app.2026-10-10.log", "app.2026-10-14.log", "app.2026-10-16.log", "other.log"} {
+		mtime := now.AddDate(0, 0, -7+i*3)
+		if i == 3 {
+			mtime = now.AddDate(-1, 0, 0)
+		}
+		if err := os.WriteFile(filepath.Join(dir, n), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, n), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := NewFileWriter(FileConfig{
+		Filename:    name,
+		RotateEvery: RotateDaily,
+		MaxAge:      5 * 24 * time.Hour,
+		Location:    time.UTC,
+		Now:         func() time.Time { return now },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if got := strings.Join(listDir(t, dir), ","); got != "app.2026-10-14.log,app.2026-10-16.log,app.log,other.log" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+}
+
+func readGzipFile(t *testing.T, name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFileWriterCompress(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	// left by a previous process.
+	if err := os.WriteFile(filepath.Join(dir, "app.2026-10-15.log"), []byte("00000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.2026-10-14.log.gz.tmp"), []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &fakeClock{now: time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)}
+	w, err := NewFileWriter(FileConfig{
+		Filename:    name,
+		MaxSize:     12,
+		RotateEvery: RotateDaily,
+		Location:    time.UTC,
+		Now:         clock.Now,
+		Compress:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("11111\n"))
+	w.Write([]byte("22222\n"))
+	w.Write([]byte("33333\n"))
+	clock.Set(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC))
+	w.Write([]byte("44444\n"))
+	w.Close()
+
+	if got := strings.Join(listDir(t, dir), ","); got != "app.2026-10-15.log.gz,app.2026-10-16.1.log.gz,app.2026-10-16.log.gz,app.log" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+	files := map[string]string{
+		"app.2026-10-15.log.gz":   "00000\n",
+		"app.2026-10-16.log.gz":   "11111\n22222\n",
+		"app.2026-10-16.1.log.gz": "33333\n",
+	}
+	for n, want := range files {
+		if got := readGzipFile(t, filepath.Join(dir, n)); got != want {
+			t.Errorf("%s: got %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFileWriterCompressIndex(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	w, err := NewFileWriter(FileConfig{Filename: name, BackupStyle: BackupIndex, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"1\n", "2\n", "3\n", "4\n"} {
+		w.Write([]byte(s))
+		w.Rotate()
+	}
+	w.Close()
+
+	names := listDir(t, dir)
+	if got := strings.Join(names, ","); got != "app.log,app.log.1.gz,app.log.2.gz" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+	if got := readGzipFile(t, name+".1.gz"); got != "4\n" {
+		t.Errorf("app.log.1.gz: %q", got)
+	}
+	if got := readGzipFile(t, name+".2.gz"); got != "3\n" {
+		t.Errorf("app.log.2.gz: %q", got)
+	}
+}
+
+func TestFileWriterDailyIgnoresBackupIndex(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	clock := &fakeClock{now: time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)}
+	w, err := NewFileWriter(FileConfig{
+		Filename:    name,
+		RotateEvery: RotateDaily,
+		BackupStyle: BackupIndex,
+		MaxBackups:  1,
+		Compress:    true,
+		Location:    time.UTC,
+		Now:         clock.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for day := 14; day <= 18; day++ {
+		clock.Set(time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC))
+		w.Write([]byte{byte('0' + day%10), '\n'})
+	}
+	w.Close()
+
+	if got := strings.Join(listDir(t, dir), ","); got != "app.2026-10-17.log.gz,app.log" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+	if got := readGzipFile(t, filepath.Join(dir, "app.2026-10-17.log.gz")); got != "7\n" {
+		t.Errorf("app.2026-10-17.log.gz: %q", got)
+	}
+}
+
+func TestFileWriterRotateError(t *testing.T) {
+	defer func(f func(string, string) error) { osRename = f }(osRename)
+	renames := 0
+	osRename = func(string, string) error {
+		renames++
+		return errors.New("rename failed")
+	}
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	var errs []error
+	w, err := NewFileWriter(FileConfig{
+		Filename:     name,
+		MaxSize:      12,
+		Location:     time.UTC,
+		Now:          clock.Now,
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"11111\n", "22222\n", "33333\n", "44444\n"} {
+		if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write: %d, %v", n, err)
+		}
+	}
+	// failed once, and not retried before rotateRetryInterval.
+	if renames != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "rename failed") {
+		t.Fatalf("renames %d, errors %v", renames, errs)
+	}
+	if got := readFile(t, name); got != "11111\n22222\n33333\n44444\n" {
+		t.Fatalf("got %q", got)
+	}
+
+	osRename = os.Rename
+	clock.Set(clock.Now().Add(rotateRetryInterval))
+	w.Write([]byte("55555\n"))
+	w.Close()
+	names := listDir(t, dir)
+	if len(names) != 2 || names[1] != "app.log" {
+		t.Fatalf("unexpected files: %v", names)
+	}
+	if got := readFile(t, filepath.Join(dir, names[0])); got != "11111\n22222\n33333\n44444\n" {
+		t.Errorf("%s: %q", names[0], got)
+	}
+	if got := readFile(t, name); got != "55555\n" {
+		t.Errorf("app.log: %q", got)
+	}
+}
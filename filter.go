@@ -0,0 +1,234 @@
+package xlog
+
+import "fmt"
+
+// filter decides whether the logs are written, it is shared by a filterLogger
+// and the loggers derived from it by With.
+type filter interface {
+	// filter reports whether the log should be written to l. Before that, it
+	// may write its own reports to l, with the caller at calldepth.
+	// s is the message without the trailing newline.
+	filter(l Logger, calldepth int, lvl Level, reqID, s string) bool
+	// flush writes the pending reports to l.
+	flush(l Logger)
+	// close stops the background work of the filter.
+	close()
+}
+
+// filterLogger is a Logger which writes the logs passing its filter to the underlying Logger.
+type filterLogger struct {
+	Logger
+	f filter
+}
+
+// newFilterLogger wraps l with f.
+// If l is a ReqLogger created by NewReqLogger, the returned Logger is a
+// ReqLogger with the same config, whose underlying Logger is wrapped.
+func newFilterLogger(l Logger, f filter) Logger {
+	if l == nil {
+		l = defaultLogger
+	}
+	if rl, ok := l.(*reqLogger); ok {
+		return rl.withLogger(&filterLogger{Logger: rl.Logger, f: f})
+	}
+	return &filterLogger{Logger: l, f: f}
+}
+
+// unwrapFilter returns the Logger wrapped by newFilterLogger, l is returned by it.
+func unwrapFilter(l Logger) Logger {
+	if rl, ok := l.(*reqLogger); ok {
+		l = rl.Logger
+	}
+	return l.(*filterLogger).Logger
+}
+
+// Output writes the log to the underlying Logger if it passes the filter.
+// The Fatal and Panic logs are always written.
+func (fl *filterLogger) Output(lvl Level, calldepth int, reqID, s string) error {
+	return fl.outputTrace(lvl, calldepth+1, reqID, "", "", s)
+}
+
+// outputTrace implements traceOutputter.
+func (fl *filterLogger) outputTrace(lvl Level, calldepth int, reqID, traceID, spanID, s string) error {
+	if lvl < LevelFatal {
+		msg := s
+		if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+			msg = msg[:len(msg)-1]
+		}
+		if !fl.f.filter(fl.Logger, calldepth+1, lvl, reqID, msg) {
+			return nil
+		}
+	}
+	return outputTrace(fl.Logger, lvl, calldepth+1, reqID, traceID, spanID, s)
+}
+
+// WriteEntry implements EntryWriter, e is written to the underlying Logger if it passes the filter.
+func (fl *filterLogger) WriteEntry(e *Entry) error {
+	if e.Level < LevelFatal {
+		msg := e.Message
+		if len(msg) > 0 && msg[len(msg)-1] == '\n' {
+			msg = msg[:len(msg)-1]
+		}
+		if !fl.f.filter(fl.Logger, 2, e.Level, e.ReqID, msg) {
+			return nil
+		}
+	}
+	return writeEntry(fl.Logger, 2, e)
+}
+
+// Sync writes the pending reports of the filter, and syncs the underlying Logger.
+func (fl *filterLogger) Sync() error {
+	fl.f.flush(fl.Logger)
+	return fl.Logger.Sync()
+}
+
+// Close writes the pending reports of the filter, and closes the underlying Logger.
+func (fl *filterLogger) Close() error {
+	fl.f.close()
+	fl.f.flush(fl.Logger)
+	return fl.Logger.Close()
+}
+
+func (fl *filterLogger) levelVar() *LevelVar {
+	return levelVarOf(fl.Logger)
+}
+
+func (fl *filterLogger) With(keyvals ...interface{}) Logger {
+	return &filterLogger{Logger: fl.Logger.With(keyvals...), f: fl.f}
+}
+
+func (fl *filterLogger) WithFields(fields Fields) Logger {
+	return &filterLogger{Logger: fl.Logger.WithFields(fields), f: fl.f}
+}
+
+func (fl *filterLogger) Print(v ...interface{}) {
+	_ = fl.Output(LevelPrint, 2, "", fmt.Sprint(v...))
+}
+
+func (fl *filterLogger) Printf(format string, v ...interface{}) {
+	_ = fl.Output(LevelPrint, 2, "", fmt.Sprintf(format, v...))
+}
+
+func (fl *filterLogger) Println(v ...interface{}) {
+	_ = fl.Output(LevelPrint, 2, "", fmt.Sprintln(v...))
+}
+
+func (fl *filterLogger) Debug(v ...interface{}) {
+	if fl.GetLevel() <= LevelDebug {
+		_ = fl.Output(LevelDebug, 2, "", fmt.Sprint(v...))
+	}
+}
+
+func (fl *filterLogger) Debugf(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelDebug {
+		_ = fl.Output(LevelDebug, 2, "", fmt.Sprintf(format, v...))
+	}
+}
+
+func (fl *filterLogger) Debugln(v ...interface{}) {
+	if fl.GetLevel() <= LevelDebug {
+		_ = fl.Output(LevelDebug, 2, "", fmt.Sprintln(v...))
+	}
+}
+
+func (fl *filterLogger) Info(v ...interface{}) {
+	if fl.GetLevel() <= LevelInfo {
+		_ = fl.Output(LevelInfo, 2, "", fmt.Sprint(v...))
+	}
+}
+
+func (fl *filterLogger) Infof(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelInfo {
+		_ = fl.Output(LevelInfo, 2, "", fmt.Sprintf(format, v...))
+	}
+}
+
+func (fl *filterLogger) Infoln(v ...interface{}) {
+	if fl.GetLevel() <= LevelInfo {
+		_ = fl.Output(LevelInfo, 2, "", fmt.Sprintln(v...))
+	}
+}
+
+func (fl *filterLogger) Warn(v ...interface{}) {
+	if fl.GetLevel() <= LevelWarn {
+		_ = fl.Output(LevelWarn, 2, "", fmt.Sprint(v...))
+	}
+}
+
+func (fl *filterLogger) Warnf(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelWarn {
+		_ = fl.Output(LevelWarn, 2, "", fmt.Sprintf(format, v...))
+	}
+}
+
+func (fl *filterLogger) Warnln(v ...interface{}) {
+	if fl.GetLevel() <= LevelWarn {
+		_ = fl.Output(LevelWarn, 2, "", fmt.Sprintln(v...))
+	}
+}
+
+func (fl *filterLogger) Error(v ...interface{}) {
+	if fl.GetLevel() <= LevelError {
+		_ = fl.Output(LevelError, 2, "", fmt.Sprint(v...))
+	}
+}
+
+func (fl *filterLogger) Errorf(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelError {
+		_ = fl.Output(LevelError, 2, "", fmt.Sprintf(format, v...))
+	}
+}
+
+func (fl *filterLogger) Errorln(v ...interface{}) {
+	if fl.GetLevel() <= LevelError {
+		_ = fl.Output(LevelError, 2, "", fmt.Sprintln(v...))
+	}
+}
+
+func (fl *filterLogger) Fatal(v ...interface{}) {
+	if fl.GetLevel() <= LevelFatal {
+		_ = fl.Output(LevelFatal, 2, "", fmt.Sprint(v...))
+		_ = fl.Close()
+		osExit(1)
+	}
+}
+
+func (fl *filterLogger) Fatalf(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelFatal {
+		_ = fl.Output(LevelFatal, 2, "", fmt.Sprintf(format, v...))
+		_ = fl.Close()
+		osExit(1)
+	}
+}
+
+func (fl *filterLogger) Fatalln(v ...interface{}) {
+	if fl.GetLevel() <= LevelFatal {
+		_ = fl.Output(LevelFatal, 2, "", fmt.Sprintln(v...))
+		_ = fl.Close()
+		osExit(1)
+	}
+}
+
+func (fl *filterLogger) Panic(v ...interface{}) {
+	if fl.GetLevel() <= LevelPanic {
+		s := fmt.Sprint(v...)
+		_ = fl.Output(LevelPanic, 2, "", s)
+		panic(s)
+	}
+}
+
+func (fl *filterLogger) Panicf(format string, v ...interface{}) {
+	if fl.GetLevel() <= LevelPanic {
+		s := fmt.Sprintf(format, v...)
+		_ = fl.Output(LevelPanic, 2, "", s)
+		panic(s)
+	}
+}
+
+func (fl *filterLogger) Panicln(v ...interface{}) {
+	if fl.GetLevel() <= LevelPanic {
+		s := fmt.Sprintln(v...)
+		_ = fl.Output(LevelPanic, 2, "", s)
+		panic(s)
+	}
+}
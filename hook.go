@@ -0,0 +1,51 @@
+package xlog
+
+import (
+	"fmt"
+	"os"
+)
+
+// Hook is fired when a log of its levels is printed.
+type Hook interface {
+	// Levels returns the levels which fire the hook.
+	Levels() []Level
+	// Fire is called after the entry is built and before it is written.
+	// It may modify e, e.g. append fields, but must not retain it.
+	// It is called concurrently.
+	Fire(e *Entry) error
+}
+
+// levelHooks is the hooks grouped by level.
+type levelHooks [LevelPanic + 1][]Hook
+
+func newLevelHooks(hooks []Hook) *levelHooks {
+	if len(hooks) == 0 {
+		return nil
+	}
+	lh := new(levelHooks)
+	for _, h := range hooks {
+		for _, lvl := range h.Levels() {
+			if int(lvl) < len(lh) {
+				lh[lvl] = append(lh[lvl], h)
+			}
+		}
+	}
+	return lh
+}
+
+// fire fires the hooks of e.Level, the errors are passed to onError.
+func (lh *levelHooks) fire(e *Entry, onError func(error)) {
+	if lh == nil || int(e.Level) >= len(lh) {
+		return
+	}
+	for _, h := range lh[e.Level] {
+		if err := h.Fire(e); err != nil {
+			onError(fmt.Errorf("xlog: failed to fire hook %T: %w", h, err))
+		}
+	}
+}
+
+// defaultErrorHandler prints err to os.Stderr.
+func defaultErrorHandler(err error) {
+	fmt.Fprintln(os.Stderr, err)
+}
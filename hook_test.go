@@ -0,0 +1,57 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type testHook struct {
+	levels []Level
+	count  int32
+	err    error
+}
+
+func (h *testHook) Levels() []Level { return h.levels }
+
+func (h *testHook) Fire(e *Entry) error {
+	atomic.AddInt32(&h.count, 1)
+	e.Fields = append(e.Fields, Field{Key: "hooked", Value: true})
+	return h.err
+}
+
+func TestHook(t *testing.T) {
+	b := new(bytes.Buffer)
+	h := &testHook{levels: []Level{LevelError, LevelFatal}}
+	failed := &testHook{levels: []Level{LevelWarn}, err: errors.New("failed")}
+	var errs []error
+	l := NewWithWriter(b, &Config{
+		Hooks:        []Hook{h, failed},
+		ErrorHandler: func(err error) { errs = append(errs, err) },
+	})
+	l2 := l.With("k", "v")
+	l2.Info("info")
+	l2.Error("error")
+	l2.Warn("warn")
+	NewReqLogger(l, ReqConfig{ReqID: "reqid"}).Errorln("reqlogger")
+
+	expected := "[INFO]info k=v\n[ERRO]error k=v hooked=true\n[WARN]warn k=v hooked=true\n[ERRO][reqid]reqlogger hooked=true\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+	if h.count != 2 || failed.count != 1 {
+		t.Fatalf("unexpected count: %d, %d", h.count, failed.count)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], failed.err) || !strings.Contains(errs[0].Error(), "*xlog.testHook") {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	// the fields appended by hooks don't change the fields of the logger.
+	b.Reset()
+	l2.Info("info")
+	if b.String() != "[INFO]info k=v\n" {
+		t.Fatalf("got %q", b.String())
+	}
+}
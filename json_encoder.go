@@ -0,0 +1,230 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// JSONEncoder writes the log as a JSON object per line:
+//
+//	{"time":"2009/01/23 01:23:23","level":"info","reqid":"reqID","caller":"d.go:23","prefix":"P","msg":"message","key":"value"}
+//
+// time is written if the corresponding flags or Config.TimeFormat are provided, it is a number for TimeUnix and TimeUnixMilli.
+// caller is written only if the corresponding flags are provided,
+// reqid and prefix only if they are not blank, trace_id and span_id only if
+// the ReqLogger has a TraceContext.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(buf *[]byte, e *Entry) {
+	*buf = append(*buf, '{')
+	if e.HasTime() {
+		*buf = append(*buf, `"time":`...)
+		if e.timeFormatter().numeric() {
+			e.AppendTime(buf)
+		} else {
+			appendJSONQuoted(buf, func(buf *[]byte) { e.AppendTime(buf) })
+		}
+		*buf = append(*buf, ',')
+	}
+	*buf = append(*buf, `"level":"`...)
+	*buf = append(*buf, e.Level.String()...)
+	*buf = append(*buf, '"')
+	if e.ReqID != "" {
+		*buf = append(*buf, `,"reqid":`...)
+		appendJSONString(buf, e.ReqID)
+	}
+	if e.TraceID != "" {
+		*buf = append(*buf, `,"trace_id":`...)
+		appendJSONString(buf, e.TraceID)
+		*buf = append(*buf, `,"span_id":`...)
+		appendJSONString(buf, e.SpanID)
+	}
+	if e.Flag&(Lshortfile|Llongfile) != 0 {
+		*buf = append(*buf, `,"caller":`...)
+		appendJSONQuoted(buf, func(buf *[]byte) { appendCaller(buf, e) })
+	}
+	if e.Prefix != "" {
+		*buf = append(*buf, `,"prefix":`...)
+		appendJSONString(buf, e.Prefix)
+	}
+	*buf = append(*buf, `,"msg":`...)
+	appendJSONString(buf, e.Message)
+	for _, f := range e.Fields {
+		*buf = append(*buf, ',')
+		appendJSONField(buf, f)
+	}
+	*buf = append(*buf, "}\n"...)
+}
+
+// appendJSONField writes f to buf as "key":value.
+func appendJSONField(buf *[]byte, f Field) {
+	appendJSONString(buf, f.Key)
+	*buf = append(*buf, ':')
+	appendJSONValue(buf, f.Value)
+}
+
+// appendJSONValue writes v to buf as a JSON value.
+// Only the types which are not handled here fall back to encoding/json.
+func appendJSONValue(buf *[]byte, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		appendJSONString(buf, v)
+	case int:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case int64:
+		*buf = strconv.AppendInt(*buf, v, 10)
+	case int32:
+		*buf = strconv.AppendInt(*buf, int64(v), 10)
+	case uint:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case uint64:
+		*buf = strconv.AppendUint(*buf, v, 10)
+	case uint32:
+		*buf = strconv.AppendUint(*buf, uint64(v), 10)
+	case float64:
+		appendJSONFloat(buf, v, 64)
+	case float32:
+		appendJSONFloat(buf, float64(v), 32)
+	case bool:
+		*buf = strconv.AppendBool(*buf, v)
+	case nil:
+		*buf = append(*buf, "null"...)
+	case []Field:
+		*buf = append(*buf, '{')
+		for i, f := range v {
+			if i > 0 {
+				*buf = append(*buf, ',')
+			}
+			appendJSONField(buf, f)
+		}
+		*buf = append(*buf, '}')
+	case time.Time:
+		*buf = append(*buf, '"')
+		*buf = v.AppendFormat(*buf, time.RFC3339Nano)
+		*buf = append(*buf, '"')
+	case json.Marshaler:
+		appendJSONMarshaler(buf, v)
+	case error:
+		appendJSONString(buf, errorString(v))
+	case fmt.Stringer:
+		appendJSONString(buf, stringerString(v))
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			appendJSONString(buf, fmt.Sprint(v))
+			return
+		}
+		*buf = append(*buf, b...)
+	}
+}
+
+// appendJSONMarshaler writes the compacted output of v.MarshalJSON to buf, so
+// that the log is still one line. A nil pointer is written as null like encoding/json.
+func appendJSONMarshaler(buf *[]byte, v json.Marshaler) {
+	if isNilPointer(v) {
+		*buf = append(*buf, "null"...)
+		return
+	}
+	b, err := v.MarshalJSON()
+	if err != nil {
+		appendJSONString(buf, fmt.Sprintf("!ERROR:%v", err))
+		return
+	}
+	// Compact appends to the bytes of *buf, which are not changed if it fails.
+	w := bytes.NewBuffer(*buf)
+	if err := json.Compact(w, b); err != nil {
+		appendJSONString(buf, fmt.Sprintf("!ERROR:%v", err))
+		return
+	}
+	*buf = w.Bytes()
+}
+
+// appendJSONFloat writes f to buf, NaN and infinities are written as strings.
+func appendJSONFloat(buf *[]byte, f float64, bitSize int) {
+	switch {
+	case math.IsNaN(f):
+		*buf = append(*buf, `"NaN"`...)
+	case math.IsInf(f, 1):
+		*buf = append(*buf, `"+Inf"`...)
+	case math.IsInf(f, -1):
+		*buf = append(*buf, `"-Inf"`...)
+	default:
+		*buf = strconv.AppendFloat(*buf, f, 'g', -1, bitSize)
+	}
+}
+
+const hex = "0123456789abcdef"
+
+// appendJSONQuoted writes the output of appendf to buf as a quoted JSON string,
+// e.g. the time in a custom layout, which may have to be escaped.
+func appendJSONQuoted(buf *[]byte, appendf func(buf *[]byte)) {
+	*buf = append(*buf, '"')
+	start := len(*buf)
+	appendf(buf)
+	for _, c := range (*buf)[start:] {
+		if c < 0x20 || c == '"' || c == '\\' || c >= utf8.RuneSelf {
+			s := string((*buf)[start:])
+			*buf = (*buf)[:start-1]
+			appendJSONString(buf, s)
+			return
+		}
+	}
+	*buf = append(*buf, '"')
+}
+
+// appendJSONString writes s to buf as a quoted JSON string.
+// Invalid UTF-8 is replaced with U+FFFD.
+func appendJSONString(buf *[]byte, s string) {
+	*buf = append(*buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			*buf = append(*buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				*buf = append(*buf, '\\', c)
+			case '\n':
+				*buf = append(*buf, '\\', 'n')
+			case '\r':
+				*buf = append(*buf, '\\', 'r')
+			case '\t':
+				*buf = append(*buf, '\\', 't')
+			default:
+				*buf = append(*buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			*buf = append(*buf, s[start:i]...)
+			*buf = append(*buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON but break JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			*buf = append(*buf, s[start:i]...)
+			*buf = append(*buf, '\\', 'u', '2', '0', '2', hex[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	*buf = append(*buf, s[start:]...)
+	*buf = append(*buf, '"')
+}
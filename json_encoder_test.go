@@ -0,0 +1,129 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Prefix: "P", Encoder: JSONEncoder{}})
+	rl := NewReqLogger(l, ReqConfig{ReqID: "reqid", Level: LevelDebug})
+	rl.With("n", 1, "err", errors.New("e"), "d", time.Second, "nil", nil, "f", math.NaN()).Warnln("hello")
+	l.Error("world")
+
+	expected := `{"level":"warning","reqid":"reqid","prefix":"P","msg":"hello","n":1,"err":"e","d":"1s","nil":null,"f":"NaN"}` + "\n"
+	expected += `{"level":"error","prefix":"P","msg":"world"}` + "\n"
+	if b.String() != expected {
+		t.Fatalf("got %s, want %s", b.String(), expected)
+	}
+}
+
+func TestJSONEncoderFlags(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Flag: LstdFlags | Lshortfile, Encoder: JSONEncoder{}})
+	l.With("s", struct{ A int }{1}).Print("hello")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatalf("invalid json %s: %v", b.String(), err)
+	}
+	if _, ok := m["time"].(string); !ok {
+		t.Errorf("time is missing: %s", b.String())
+	}
+	if c, _ := m["caller"].(string); !bytes.HasPrefix([]byte(c), []byte("json_encoder_test.go:")) {
+		t.Errorf("wrong caller: %s", b.String())
+	}
+	if s, _ := m["s"].(map[string]interface{}); s["A"] != 1.0 {
+		t.Errorf("wrong field s: %s", b.String())
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	cases := []string{
+		"",
+		"abc",
+		`a"b\c`,
+		"a\nb\rc\td\x00\x1f",
+		"中文",
+		"\u2028\u2029",
+		"<html>&",
+	}
+	for _, s := range cases {
+		var buf []byte
+		appendJSONString(&buf, s)
+		var got string
+		if err := json.Unmarshal(buf, &got); err != nil {
+			t.Fatalf("invalid json %s: %v", buf, err)
+		}
+		if got != s {
+			t.Errorf("got %q, want %q", got, s)
+		}
+	}
+
+	var buf []byte
+	appendJSONString(&buf, "a\xffb")
+	if string(buf) != `"a\ufffdb"` {
+		t.Errorf("invalid utf-8 is not replaced: %s", buf)
+	}
+}
+
+func TestJSONEncoderTimeFormat(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{TimeFormat: "2006-01-02 \"15\"\\", Encoder: JSONEncoder{}})
+	l.Info("hello")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatalf("invalid json %s: %v", b.String(), err)
+	}
+	if tm, _ := m["time"].(string); !strings.HasSuffix(tm, "\"\\") || strings.Count(tm, "\"") != 2 {
+		t.Fatalf("wrong time: %s", b.String())
+	}
+}
+
+type rawMarshaler string
+
+func (m *rawMarshaler) MarshalJSON() ([]byte, error) { return []byte(*m), nil }
+
+func TestJSONEncoderMarshaler(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Encoder: JSONEncoder{}})
+	indented, invalid := rawMarshaler("{\n  \"a\": 1\n}"), rawMarshaler(`{"a":`)
+	var nilMarshaler *rawMarshaler
+	l.With("v", &indented, "bad", &invalid, "nil", nilMarshaler).Info("x")
+
+	expected := `{"level":"info","msg":"x","v":{"a":1},"bad":"!ERROR:unexpected end of JSON input","nil":null}` + "\n"
+	if b.String() != expected {
+		t.Fatalf("got %s, want %s", b.String(), expected)
+	}
+}
+
+// raceEnabled is set if the race detector is on, which makes sync.Pool drop items randomly.
+var raceEnabled bool
+
+func TestJSONEncoderAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool is not reliable with the race detector")
+	}
+	text := NewWithWriter(io.Discard, &Config{Flag: LstdFlags}).With("k", "v")
+	js := NewWithWriter(io.Discard, &Config{Flag: LstdFlags, Encoder: JSONEncoder{}}).With("k", "v")
+	textAllocs := testing.AllocsPerRun(100, func() { text.Print("hello") })
+	jsonAllocs := testing.AllocsPerRun(100, func() { js.Print("hello") })
+	if jsonAllocs > textAllocs {
+		t.Fatalf("json allocs %v > text allocs %v", jsonAllocs, textAllocs)
+	}
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	l := NewWithWriter(io.Discard, &Config{Flag: LstdFlags, Encoder: JSONEncoder{}}).With("k", "v", "n", 1)
+	for i := 0; i < b.N; i++ {
+		l.Println("test")
+	}
+}
@@ -3,6 +3,7 @@ package xlog
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 )
 
 // Log Level
@@ -105,3 +106,44 @@ func ParseLevel(lvl string) (Level, error) {
 	var l Level
 	return l, fmt.Errorf("not a valid Level: %q", lvl)
 }
+
+// LevelVar is a Level which can be changed concurrently.
+// Share it by Config.LevelVar and ReqConfig.LevelVar to change the level of
+// the Logger and ReqLoggers at runtime.
+type LevelVar struct {
+	v uint32
+}
+
+// NewLevelVar creates a LevelVar with the level lvl.
+func NewLevelVar(lvl Level) *LevelVar {
+	v := new(LevelVar)
+	v.SetLevel(lvl)
+	return v
+}
+
+// GetLevel returns the current level.
+func (v *LevelVar) GetLevel() Level {
+	return Level(atomic.LoadUint32(&v.v))
+}
+
+// SetLevel changes the level to lvl.
+func (v *LevelVar) SetLevel(lvl Level) {
+	atomic.StoreUint32(&v.v, uint32(lvl))
+}
+
+func (v *LevelVar) String() string {
+	return v.GetLevel().String()
+}
+
+// levelVarer is implemented by the Loggers of this package to share their LevelVar.
+type levelVarer interface {
+	levelVar() *LevelVar
+}
+
+// levelVarOf returns the LevelVar of l, or nil if l doesn't have one.
+func levelVarOf(l Logger) *LevelVar {
+	if lv, ok := l.(levelVarer); ok {
+		return lv.levelVar()
+	}
+	return nil
+}
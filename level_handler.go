@@ -0,0 +1,180 @@
+package xlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Leveler is the interface to get and change a level.
+// LevelVar, Logger and ReqLogger implement it.
+type Leveler interface {
+	GetLevel() Level
+	SetLevel(lvl Level)
+}
+
+var timeAfterFunc = time.AfterFunc // for testing
+
+// LevelHandler is an http.Handler to view and change the level of a Leveler.
+//
+// GET returns the current level as JSON:
+//
+//	{"level":"info"}
+//
+// PUT or POST changes the level. The parameter "level" is parsed by ParseLevel,
+// and the optional parameter "revert" is a duration after which the level is
+// reverted. The parameters are read from the JSON body, which is accepted with
+// the form Content-Type of `curl -d` too, or the form:
+//
+//	curl -X PUT -d '{"level":"debug","revert":"10m"}' http://host/log/level
+//	curl -X PUT -d 'level=debug&revert=10m' http://host/log/level
+//
+// While a revert is pending, the response has the level to revert to and when:
+//
+//	{"level":"debug","revert_to":"info","revert_at":"2026-10-17T10:10:00Z"}
+type LevelHandler struct {
+	l Leveler
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	revertTo Level
+	revertAt time.Time
+}
+
+// NewLevelHandler creates a LevelHandler which changes the level of l.
+func NewLevelHandler(l Leveler) *LevelHandler {
+	return &LevelHandler{l: l}
+}
+
+type levelRequest struct {
+	Level  string `json:"level"`
+	Revert string `json:"revert"`
+}
+
+type levelResponse struct {
+	Level    string     `json:"level"`
+	RevertTo string     `json:"revert_to,omitempty"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		if err := h.setLevel(r); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed: " + r.Method})
+		return
+	}
+	writeJSON(w, http.StatusOK, h.status())
+}
+
+func (h *LevelHandler) setLevel(r *http.Request) error {
+	var req levelRequest
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return fmt.Errorf("invalid body: %v", err)
+		}
+	} else if body, ok := jsonBody(r, ct); ok {
+		// A JSON body with the form Content-Type of `curl -d`, or without Content-Type.
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid body: %v", err)
+		}
+	} else {
+		req.Level = r.FormValue("level")
+		req.Revert = r.FormValue("revert")
+	}
+
+	lvl, err := ParseLevel(req.Level)
+	if err != nil {
+		return err
+	}
+	var revert time.Duration
+	if req.Revert != "" {
+		if revert, err = time.ParseDuration(req.Revert); err != nil {
+			return fmt.Errorf("invalid revert: %v", err)
+		}
+		if revert <= 0 {
+			return fmt.Errorf("invalid revert: %q, must be positive", req.Revert)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// If a revert is pending, keep reverting to the level before it.
+	revertTo := h.l.GetLevel()
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+		revertTo = h.revertTo
+	}
+	h.l.SetLevel(lvl)
+	if revert > 0 {
+		var timer *time.Timer
+		timer = timeAfterFunc(revert, func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if h.timer != timer {
+				return // stopped by another request.
+			}
+			h.l.SetLevel(h.revertTo)
+			h.timer = nil
+		})
+		h.timer = timer
+		h.revertTo = revertTo
+		h.revertAt = time.Now().Add(revert)
+	}
+	return nil
+}
+
+// maxLevelBody is the max size of the body read by jsonBody.
+const maxLevelBody = 1 << 16
+
+// jsonBody returns the body of r if it is a JSON object with the Content-Type
+// of a form or without one, otherwise the body is kept to be parsed as a form.
+func jsonBody(r *http.Request, ct string) ([]byte, bool) {
+	if (ct != "" && ct != "application/x-www-form-urlencoded") || r.Body == nil {
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLevelBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return nil, false
+	}
+	if b := bytes.TrimLeft(body, " \t\r\n"); len(b) == 0 || b[0] != '{' {
+		return nil, false
+	}
+	return body, true
+}
+
+func (h *LevelHandler) status() levelResponse {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	resp := levelResponse{Level: h.l.GetLevel().String()}
+	if h.timer != nil {
+		revertAt := h.revertAt
+		resp.RevertTo = h.revertTo.String()
+		resp.RevertAt = &revertAt
+	}
+	return resp
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,103 @@
+package xlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelHandler(t *testing.T) {
+	var revert func()
+	timeAfterFunc = func(d time.Duration, f func()) *time.Timer {
+		if d != 10*time.Minute {
+			t.Fatalf("unexpected duration: %v", d)
+		}
+		revert = f
+		return time.AfterFunc(time.Hour, func() {})
+	}
+	defer func() { timeAfterFunc = time.AfterFunc }()
+
+	lv := NewLevelVar(LevelInfo)
+	h := NewLevelHandler(lv)
+	do := func(method, body, contentType string) (int, string) {
+		r := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	if code, body := do(http.MethodGet, "", ""); code != 200 || body != `{"level":"info"}` {
+		t.Fatalf("GET: %d %s", code, body)
+	}
+
+	code, body := do(http.MethodPut, `{"level":"debug","revert":"10m"}`, "application/json")
+	if code != 200 || !strings.HasPrefix(body, `{"level":"debug","revert_to":"info","revert_at":`) {
+		t.Fatalf("PUT: %d %s", code, body)
+	}
+	if lv.GetLevel() != LevelDebug {
+		t.Fatalf("level is not changed: %v", lv)
+	}
+
+	// change again before reverting, it still reverts to info.
+	code, body = do(http.MethodPost, "level=warn&revert=10m", "application/x-www-form-urlencoded")
+	if code != 200 || !strings.HasPrefix(body, `{"level":"warning","revert_to":"info"`) {
+		t.Fatalf("POST: %d %s", code, body)
+	}
+	revert()
+	if lv.GetLevel() != LevelInfo {
+		t.Fatalf("level is not reverted: %v", lv)
+	}
+	if code, body := do(http.MethodGet, "", ""); code != 200 || body != `{"level":"info"}` {
+		t.Fatalf("GET after revert: %d %s", code, body)
+	}
+
+	// JSON body without Content-Type.
+	if code, body := do(http.MethodPut, `{"level":"error"}`, ""); code != 200 || body != `{"level":"error"}` {
+		t.Fatalf("PUT without Content-Type: %d %s", code, body)
+	}
+	// JSON body with the form Content-Type, like `curl -d`.
+	if code, body := do(http.MethodPut, `{"level":"warn"}`, "application/x-www-form-urlencoded"); code != 200 || body != `{"level":"warning"}` {
+		t.Fatalf("PUT with the form Content-Type: %d %s", code, body)
+	}
+	if code, body := do(http.MethodPut, `level=error`, "application/x-www-form-urlencoded"); code != 200 || body != `{"level":"error"}` {
+		t.Fatalf("PUT form: %d %s", code, body)
+	}
+
+	for _, c := range []string{`{"level":"invalid"}`, `{"level":"debug","revert":"abc"}`, `{"level":"debug","revert":"-1s"}`, `{`} {
+		if code, body := do(http.MethodPut, c, "application/json"); code != http.StatusBadRequest {
+			t.Fatalf("PUT %s: %d %s", c, code, body)
+		}
+	}
+	if code, _ := do(http.MethodDelete, "", ""); code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE: %d", code)
+	}
+	if lv.GetLevel() != LevelError {
+		t.Fatalf("level is changed by bad requests: %v", lv)
+	}
+}
+
+func TestLevelHandlerLogger(t *testing.T) {
+	l := NewWithWriter(nil, &Config{Level: LevelError})
+	srv := httptest.NewServer(NewLevelHandler(l))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(`{"level":"debug","revert":"20ms"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if l.GetLevel() != LevelDebug {
+		t.Fatalf("level is not changed: %v", l.GetLevel())
+	}
+	for i := 0; i < 100 && l.GetLevel() != LevelError; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if l.GetLevel() != LevelError {
+		t.Fatalf("level is not reverted: %v", l.GetLevel())
+	}
+}
@@ -2,8 +2,10 @@ package xlog
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -131,3 +133,58 @@ func TestParseLevel(t *testing.T) {
 		}
 	}
 }
+
+func TestLevelVar(t *testing.T) {
+	b := new(bytes.Buffer)
+	lv := NewLevelVar(LevelError)
+	l := NewWithWriter(b, &Config{LevelVar: lv})
+	rl := NewReqLogger(l, ReqConfig{ReqID: "reqid", LevelVar: lv})
+	rl2 := rl.With("k", "v").(ReqLogger)
+
+	l.Info("1")
+	rl.Info("2")
+	rl2.Info("3")
+	lv.SetLevel(LevelInfo)
+	l.Info("4")
+	rl.Info("5")
+	rl2.Info("6")
+	rl2.SetLevel(LevelError)
+	l.Info("7")
+
+	expected := "[INFO]4\n[INFO][reqid]5\n[INFO][reqid]6 k=v\n"
+	if b.String() != expected {
+		t.Fatalf("got %q, want %q", b.String(), expected)
+	}
+	if l.GetLevel() != LevelError || l.CopyConfig().Level != LevelError {
+		t.Fatalf("level is not changed by rl2: %v", l.GetLevel())
+	}
+
+	// the level of a ReqLogger without LevelVar is independent.
+	rl3 := NewReqLogger(l, ReqConfig{Level: LevelDebug})
+	rl3.SetLevel(LevelPanic)
+	if l.GetLevel() != LevelError {
+		t.Fatalf("level is changed by rl3: %v", l.GetLevel())
+	}
+}
+
+func TestLevelVarConcurrent(t *testing.T) {
+	lv := NewLevelVar(LevelInfo)
+	l := NewWithWriter(io.Discard, &Config{LevelVar: lv})
+	rl := NewReqLogger(l, ReqConfig{LevelVar: lv})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				l.Debug("debug")
+				rl.Info("info")
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		lv.SetLevel(Level(i % int(LevelPanic)))
+	}
+	wg.Wait()
+}
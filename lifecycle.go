@@ -0,0 +1,47 @@
+package xlog
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Shutdown flushes the default logger by Sync, it returns ctx.Err() if ctx is
+// done before that. The default logger is not closed, so it can still be used,
+// e.g. by the goroutines which are not stopped yet.
+func Shutdown(ctx context.Context) error {
+	l := defaultLogger
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Sync()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// syncWriter syncs w if it has the method `Sync() error`, except os.Stdout and os.Stderr.
+func syncWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		// Syncing a terminal or pipe fails on some platforms.
+		return nil
+	}
+	if s, ok := w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// closeWriter closes w if it implements io.Closer, except os.Stdout and os.Stderr.
+func closeWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
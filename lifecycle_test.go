@@ -0,0 +1,115 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+type syncCloser struct {
+	bytes.Buffer
+	synced, closed int
+}
+
+func (w *syncCloser) Sync() error  { w.synced++; return nil }
+func (w *syncCloser) Close() error { w.closed++; return nil }
+
+func TestSyncClose(t *testing.T) {
+	var w syncCloser
+	l := NewWithWriter(&w, nil).With("k", "v")
+	if err := l.Sync(); err != nil || w.synced != 1 {
+		t.Fatalf("Sync: err %v, synced %d", err, w.synced)
+	}
+	if err := l.Close(); err != nil || w.synced != 2 || w.closed != 1 {
+		t.Fatalf("Close: err %v, synced %d, closed %d", err, w.synced, w.closed)
+	}
+
+	// stdout is never closed.
+	if err := New(nil).Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncCloseAsync(t *testing.T) {
+	var w syncCloser
+	l := NewWithWriter(&w, &Config{Async: &AsyncConfig{}})
+	l.Info("hello")
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "[INFO]hello\n" || w.synced != 1 {
+		t.Fatalf("got %q, synced %d", w.String(), w.synced)
+	}
+	l.Info("world")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "[INFO]hello\n[INFO]world\n" || w.closed != 1 {
+		t.Fatalf("got %q, closed %d", w.String(), w.closed)
+	}
+}
+
+func TestFatalCloses(t *testing.T) {
+	defer func(f func(int)) { osExit = f }(osExit)
+	var code int
+	osExit = func(c int) { code = c }
+
+	var w syncCloser
+	l := NewWithWriter(&w, &Config{Async: &AsyncConfig{}})
+	l.Fatal("bye")
+	if code != 1 || w.String() != "[FATA]bye\n" || w.synced != 1 || w.closed != 1 {
+		t.Fatalf("code %d, got %q, synced %d, closed %d", code, w.String(), w.synced, w.closed)
+	}
+
+	var w2 syncCloser
+	NewReqLogger(NewWithWriter(&w2, nil), ReqConfig{ReqID: "id"}).Fatalf("%s", "bye")
+	if w2.String() != "[FATA][id]bye\n" || w2.closed != 1 {
+		t.Fatalf("got %q, closed %d", w2.String(), w2.closed)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	defer func(l Logger) { defaultLogger = l }(defaultLogger)
+	var w syncCloser
+	defaultLogger = NewWithWriter(&w, &Config{Async: &AsyncConfig{}})
+	Info("hello")
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "[INFO]hello\n" || w.synced != 1 || w.closed != 0 {
+		t.Fatalf("got %q, synced %d, closed %d", w.String(), w.synced, w.closed)
+	}
+	// the default logger can still be used.
+	Info("world")
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "[INFO]hello\n[INFO]world\n" {
+		t.Fatalf("got %q", w.String())
+	}
+
+	// the deadline is exceeded while the writer is blocked.
+	bw := &notifySyncer{blockingWriter: newBlockingWriter(), synced: make(chan struct{})}
+	defaultLogger = NewWithWriter(bw, &Config{Async: &AsyncConfig{}})
+	Info("blocked")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expect DeadlineExceeded, got %v", err)
+	}
+	// the logger is still synced in the background.
+	close(bw.release)
+	<-bw.synced
+}
+
+// notifySyncer closes the channel synced when it is synced.
+type notifySyncer struct {
+	*blockingWriter
+	synced chan struct{}
+}
+
+func (w *notifySyncer) Sync() error {
+	close(w.synced)
+	return nil
+}
@@ -0,0 +1,70 @@
+package xlog
+
+// LogfmtEncoder writes the log as logfmt key=value pairs:
+//
+//	ts="2009/01/23 01:23:23" level=info reqid=reqID caller=d.go:23 prefix=P msg="a message" key=value
+//
+// ts is written if the corresponding flags or Config.TimeFormat are provided,
+// caller only if the corresponding flags are provided,
+// reqid and prefix only if they are not blank, trace_id and span_id only if
+// the ReqLogger has a TraceContext.
+// Values are quoted and escaped when necessary.
+type LogfmtEncoder struct{}
+
+// Encode implements Encoder.
+func (LogfmtEncoder) Encode(buf *[]byte, e *Entry) {
+	if e.HasTime() {
+		*buf = append(*buf, "ts="...)
+		start := len(*buf)
+		e.AppendTime(buf)
+		quoteTail(buf, start)
+		*buf = append(*buf, ' ')
+	}
+	*buf = append(*buf, "level="...)
+	*buf = append(*buf, e.Level.String()...)
+	if e.ReqID != "" {
+		*buf = append(*buf, " reqid="...)
+		appendTextString(buf, e.ReqID)
+	}
+	if e.TraceID != "" {
+		*buf = append(*buf, " trace_id="...)
+		appendTextString(buf, e.TraceID)
+		*buf = append(*buf, " span_id="...)
+		appendTextString(buf, e.SpanID)
+	}
+	if e.Flag&(Lshortfile|Llongfile) != 0 {
+		*buf = append(*buf, " caller="...)
+		start := len(*buf)
+		appendCaller(buf, e)
+		quoteTail(buf, start)
+	}
+	if e.Prefix != "" {
+		*buf = append(*buf, " prefix="...)
+		appendTextString(buf, e.Prefix)
+	}
+	*buf = append(*buf, " msg="...)
+	appendTextString(buf, e.Message)
+	appendFields(buf, e.Fields)
+	*buf = append(*buf, '\n')
+}
+
+// quoteTail quotes (*buf)[start:] if it needs to be quoted.
+// It is done in place if there is nothing to escape, e.g. a time with spaces.
+func quoteTail(buf *[]byte, start int) {
+	tail := (*buf)[start:]
+	if !needsQuote(string(tail)) {
+		return
+	}
+	for _, c := range tail {
+		if c < ' ' || c == '"' || c == '\\' || c >= 0x7f {
+			s := string(tail)
+			*buf = (*buf)[:start]
+			appendTextString(buf, s)
+			return
+		}
+	}
+	*buf = append(*buf, 0)
+	copy((*buf)[start+1:], (*buf)[start:])
+	(*buf)[start] = '"'
+	*buf = append(*buf, '"')
+}
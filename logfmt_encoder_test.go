@@ -0,0 +1,46 @@
+package xlog
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Prefix: "P", Encoder: LogfmtEncoder{}})
+	rl := NewReqLogger(l, ReqConfig{ReqID: "reqid", Level: LevelDebug})
+	rl.With("k", "a b", "n", 1).Infoln("hello world")
+	l.Error(`say "hi"`)
+	l.Print("")
+
+	expected := `level=info reqid=reqid prefix=P msg="hello world" k="a b" n=1` + "\n"
+	expected += `level=error prefix=P msg="say \"hi\""` + "\n"
+	expected += `level=print prefix=P msg=""` + "\n"
+	if b.String() != expected {
+		t.Fatalf("got %s, want %s", b.String(), expected)
+	}
+}
+
+func TestLogfmtEncoderFlags(t *testing.T) {
+	cases := []struct {
+		flag    int
+		pattern string
+	}{
+		{0, `^level=info msg=hello$`},
+		{Ldate, `^ts=` + Rdate + ` level=info msg=hello$`},
+		{Ltime | Lmicroseconds, `^ts=` + Rtime + Rmicroseconds + ` level=info msg=hello$`},
+		{LstdFlags, `^ts="` + Rdate + ` ` + Rtime + `" level=info msg=hello$`},
+		{Lshortfile, `^level=info caller=logfmt_encoder_test.go:[0-9]+ msg=hello$`},
+		{Llongfile, `^level=info caller=.*/logfmt_encoder_test.go:[0-9]+ msg=hello$`},
+	}
+	for _, c := range cases {
+		b := new(bytes.Buffer)
+		l := NewWithWriter(b, &Config{Flag: c.flag, Encoder: LogfmtEncoder{}})
+		l.Info("hello")
+		line := b.String()
+		if !regexp.MustCompile(c.pattern).MatchString(line[:len(line)-1]) {
+			t.Errorf("flag %d: %q does not match %q", c.flag, line, c.pattern)
+		}
+	}
+}
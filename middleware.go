@@ -0,0 +1,265 @@
+package xlog
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Default headers of the request id.
+const (
+	HeaderReqID     = "X-Reqid"
+	HeaderRequestID = "X-Request-Id"
+)
+
+// MiddlewareConfig is the config of NewMiddleware.
+type MiddlewareConfig struct {
+	// Logger is the underlying Logger of the ReqLoggers, the default logger is used if it is nil.
+	Logger Logger
+	// ReqConfig is the config of the ReqLoggers, except the ReqID. Set its
+	// InheritLevel to make the ReqLoggers follow the level of Logger.
+	ReqConfig ReqConfig
+	// Headers are the request headers which the request id is read from, in order.
+	// They are HeaderReqID and HeaderRequestID by default.
+	Headers []string
+	// ResponseHeader is the response header which the request id is written to,
+	// it is the first of Headers by default.
+	ResponseHeader string
+	// ValidReqID reports whether the request id from the headers is valid,
+	// otherwise a new one is generated by ReqIDGen. By default, ValidReqID is used.
+	ValidReqID func(id string) bool
+	// StartTrace starts a new sampled trace if the request has no valid traceparent header.
+	// If it has, the ReqLogger is always a child span of it, see ReqConfig.Trace.
+	StartTrace bool
+	// AccessLog writes an access log through the ReqLogger after each request:
+	//
+	//	[INFO][reqID]access method=GET path=/a status=200 bytes=12 duration=1.2ms
+	AccessLog bool
+	// AccessLogLevel is the level of the access logs, LevelInfo by default.
+	AccessLogLevel Level
+}
+
+// ValidReqID reports whether id is a valid request id from the client: at most
+// 128 bytes of letters, digits and "-_.:=+/".
+func ValidReqID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '=', c == '+', c == '/':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NewMiddleware returns an http.Handler which creates a ReqLogger for each
+// request, and puts it into the context of the request before calling next,
+// see FromContext. The request id is read from the request headers, or
+// generated by ReqIDGen, and it is written to the response header.
+// The trace context is read from the traceparent header.
+func NewMiddleware(next http.Handler, c MiddlewareConfig) http.Handler {
+	if len(c.Headers) == 0 {
+		c.Headers = []string{HeaderReqID, HeaderRequestID}
+	}
+	if c.ResponseHeader == "" {
+		c.ResponseHeader = c.Headers[0]
+	}
+	if c.ValidReqID == nil {
+		c.ValidReqID = ValidReqID
+	}
+	if c.AccessLogLevel == LevelPrint {
+		c.AccessLogLevel = LevelInfo
+	}
+	return &middleware{next: next, c: c}
+}
+
+type middleware struct {
+	next http.Handler
+	c    MiddlewareConfig
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rc := m.c.ReqConfig
+	rc.ReqID = m.reqID(r)
+	if tc, ok := m.trace(r); ok {
+		rc.Trace = tc.NewChild()
+	} else if m.c.StartTrace {
+		rc.Trace = NewTraceContext(true)
+	}
+	rl := NewReqLogger(m.c.Logger, rc)
+	rc.ReqID = rl.RequestConfig().ReqID // generated by ReqIDGen.
+	if rc.ReqID != "" {
+		w.Header().Set(m.c.ResponseHeader, rc.ReqID)
+	}
+	r = r.WithContext(NewContext(r.Context(), rl))
+
+	if !m.c.AccessLog {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	rw := &responseWriter{ResponseWriter: w}
+	defer func() {
+		if rl.GetLevel() > m.c.AccessLogLevel {
+			return
+		}
+		_ = rl.With(
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rw.statusCode(),
+			"bytes", rw.bytes,
+			"duration", time.Since(start),
+		).Output(m.c.AccessLogLevel, 1, rc.ReqID, "access")
+	}()
+	m.next.ServeHTTP(rw.wrap(), r)
+}
+
+// reqID returns the first valid request id in the request headers.
+func (m *middleware) reqID(r *http.Request) string {
+	for _, h := range m.c.Headers {
+		if id := r.Header.Get(h); id != "" && m.c.ValidReqID(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// trace returns the trace context in the traceparent header.
+func (m *middleware) trace(r *http.Request) (TraceContext, bool) {
+	h := r.Header.Get(HeaderTraceparent)
+	if h == "" {
+		return TraceContext{}, false
+	}
+	tc, err := ParseTraceparent(h)
+	return tc, err == nil
+}
+
+// responseWriter records the status code and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// flush implements http.Flusher, the underlying ResponseWriter must implement it.
+func (w *responseWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+// hijack implements http.Hijacker, the underlying ResponseWriter must implement it.
+// The status code of a hijacked connection is 101 if it is not written.
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// push implements http.Pusher, the underlying ResponseWriter must implement it.
+func (w *responseWriter) push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type (
+	rwFlusher  struct{ *responseWriter }
+	rwHijacker struct{ *responseWriter }
+	rwPusher   struct{ *responseWriter }
+)
+
+func (w rwFlusher) Flush() { w.flush() }
+
+func (w rwHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+func (w rwPusher) Push(target string, opts *http.PushOptions) error { return w.push(target, opts) }
+
+// wrap returns w with the optional interfaces http.Flusher, http.Hijacker and
+// http.Pusher which are implemented by the underlying ResponseWriter, so that
+// the handlers checking them by type assertions, e.g. for SSE and WebSocket,
+// work as without the middleware.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, f := w.ResponseWriter.(http.Flusher)
+	_, h := w.ResponseWriter.(http.Hijacker)
+	_, p := w.ResponseWriter.(http.Pusher)
+	switch {
+	case f && h && p:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, rwFlusher{w}, rwHijacker{w}, rwPusher{w}}
+	case f && h:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, rwFlusher{w}, rwHijacker{w}}
+	case f && p:
+		return struct {
+			*responseWriter
+			http.Flusher
+			http.Pusher
+		}{w, rwFlusher{w}, rwPusher{w}}
+	case h && p:
+		return struct {
+			*responseWriter
+			http.Hijacker
+			http.Pusher
+		}{w, rwHijacker{w}, rwPusher{w}}
+	case f:
+		return struct {
+			*responseWriter
+			http.Flusher
+		}{w, rwFlusher{w}}
+	case h:
+		return struct {
+			*responseWriter
+			http.Hijacker
+		}{w, rwHijacker{w}}
+	case p:
+		return struct {
+			*responseWriter
+			http.Pusher
+		}{w, rwPusher{w}}
+	}
+	return w
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// statusCode returns the status code of the response, 200 if nothing is written.
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,168 @@
+package xlog
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, nil)
+	h := NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl, ok := FromContext(r.Context())
+		if !ok {
+			t.Fatal("no ReqLogger in the context")
+		}
+		rl.Info("handled")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("hello"))
+	}), MiddlewareConfig{Logger: l, AccessLog: true})
+
+	do := func(header, id string) string {
+		b.Reset()
+		r := httptest.NewRequest(http.MethodPost, "/a/b?q=1", nil)
+		if header != "" {
+			r.Header.Set(header, id)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusTeapot || w.Body.String() != "hello" {
+			t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+		}
+		return w.Header().Get(HeaderReqID)
+	}
+
+	if id := do(HeaderReqID, "abc"); id != "abc" {
+		t.Fatalf("expect abc, got %q", id)
+	}
+	pattern := `^\[INFO\]\[abc\]handled\n\[INFO\]\[abc\]access method=POST path=/a/b status=418 bytes=5 duration=[0-9.]+[µnm]?s\n$`
+	if !regexp.MustCompile(pattern).MatchString(b.String()) {
+		t.Fatalf("%q doesn't match %q", b.String(), pattern)
+	}
+
+	if id := do(HeaderRequestID, "def"); id != "def" {
+		t.Fatalf("expect def, got %q", id)
+	}
+	if !strings.HasPrefix(b.String(), "[INFO][def]handled\n") {
+		t.Fatalf("got %q", b.String())
+	}
+
+	// an invalid id is replaced.
+	for _, id := range []string{"", "a b", "x\ny", strings.Repeat("a", 129)} {
+		got := do(HeaderReqID, id)
+		if got == "" || got == id || !strings.HasPrefix(b.String(), "[INFO]["+got+"]handled\n") {
+			t.Fatalf("id %q: got %q, logs %q", id, got, b.String())
+		}
+	}
+}
+
+func TestMiddlewareConfig(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, nil)
+	h := NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		FromContextSafe(r.Context()).Debug("debug")
+	}), MiddlewareConfig{
+		Logger:         l,
+		ReqConfig:      ReqConfig{Level: LevelDebug},
+		Headers:        []string{"X-Trace"},
+		ResponseHeader: "X-Resp-Id",
+		ValidReqID:     func(id string) bool { return strings.HasPrefix(id, "ok") },
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Trace", "ok1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("X-Resp-Id") != "ok1" || b.String() != "[DEBU][ok1]debug\n" {
+		t.Fatalf("header %q, logs %q", w.Header().Get("X-Resp-Id"), b.String())
+	}
+}
+
+// chanWriter sends each write to a channel.
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	logs := make(chanWriter, 10)
+	h := NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Pusher); ok {
+			t.Error("expect no http.Pusher for HTTP/1.1")
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+	}), MiddlewareConfig{Logger: NewWithWriter(logs, nil), AccessLog: true})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET /ws HTTP/1.1\r\nHost: x\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nX-Reqid: ws\r\n\r\n"))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if got := <-logs; !strings.HasPrefix(got, "[INFO][ws]access method=GET path=/ws status=101 bytes=0 ") {
+		t.Fatalf("got %q", got)
+	}
+
+	// only the interfaces implemented by the underlying ResponseWriter are exposed.
+	h = NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("expect no http.Hijacker")
+		}
+		if _, ok := w.(http.Pusher); ok {
+			t.Error("expect no http.Pusher")
+		}
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expect http.Flusher")
+		}
+		f.Flush()
+	}), MiddlewareConfig{Logger: NewWithWriter(logs, nil), AccessLog: true})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := <-logs; !strings.Contains(got, " status=200 ") || !rec.Flushed {
+		t.Fatalf("got %q, flushed %v", got, rec.Flushed)
+	}
+
+	h = NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Error("expect no http.Flusher")
+		}
+		if err := http.NewResponseController(w).Flush(); err == nil {
+			t.Error("expect an error of ResponseController")
+		}
+	}), MiddlewareConfig{Logger: NewWithWriter(logs, nil), AccessLog: true})
+	h.ServeHTTP(plainResponseWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	<-logs
+}
+
+// plainResponseWriter only implements http.ResponseWriter.
+type plainResponseWriter struct {
+	w http.ResponseWriter
+}
+
+func (w plainResponseWriter) Header() http.Header         { return w.w.Header() }
+func (w plainResponseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w plainResponseWriter) WriteHeader(code int)        { w.w.WriteHeader(code) }
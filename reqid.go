@@ -5,13 +5,22 @@ import (
 	"encoding/binary"
 	"fmt"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
 var pid = uint32(os.Getpid())
 
 // ReqIDGen generates request id。
-var ReqIDGen = func() string {
+// It is DefaultReqIDGen by default, and can be set to the other generators
+// like CounterReqIDGen, UUIDv4ReqIDGen and so on.
+var ReqIDGen = DefaultReqIDGen
+
+// DefaultReqIDGen generates a request id of 16 characters, which is the
+// URL-base64 of the pid and the current time in nanoseconds.
+// The ids generated in the same nanosecond are the same, CounterReqIDGen fixes it.
+func DefaultReqIDGen() string {
 	var buf [12]byte
 	binary.LittleEndian.PutUint32(buf[:4], pid)
 	binary.LittleEndian.PutUint64(buf[4:], uint64(time.Now().UnixNano()))
@@ -23,18 +32,40 @@ var ReqIDGen = func() string {
 type ReqLogger interface {
 	Logger
 	RequestConfig() *ReqConfig
+	// StartSpan returns a ReqLogger for a sub-operation, whose TraceContext is a
+	// child span of the receiver's, or a new trace if the receiver has none.
+	// The others are the same as the receiver.
+	StartSpan() ReqLogger
+	// Fork returns a ReqLogger for a branch of the request, e.g. a goroutine or
+	// a downstream call, whose request id is the receiver's with a sequence number
+	// like "abc123.1", "abc123.2", and "abc123.2.1" if it is forked again.
+	// The numbers are shared by the receiver and the Loggers returned by its With,
+	// and are safe for concurrent use. The others are the same as the receiver.
+	Fork() ReqLogger
 }
 
 // ReqConfig is the config of ReqLogger.
 type ReqConfig struct {
-	ReqID string // if it is empty, call ReqIDGen to generate it.
-	Level Level
+	ReqID    string // if it is empty, call ReqIDGen to generate it.
+	Level    Level
+	LevelVar *LevelVar // if it is not nil, it is used instead of Level, and can be shared with other Loggers.
+	// InheritLevel makes the ReqLogger share the level of the underlying Logger
+	// instead of Level, if LevelVar is not set, so that changing the level of the
+	// Logger, e.g. by LevelHandler, applies to the ReqLogger, and SetLevel of the
+	// ReqLogger changes the Logger too.
+	InheritLevel bool
+	// Trace is the W3C trace context, its ids are printed with the request id if it is valid.
+	Trace TraceContext
 }
 
 type reqLogger struct {
 	ReqConfig
 	Logger
 	calldepth int
+	lv        *LevelVar
+	traceID   string // the hex ids of Trace, empty if Trace is not valid.
+	spanID    string
+	forks     *uint32 // the last sequence number of Fork.
 }
 
 // NewReqLogger creates a ReqLogger.
@@ -48,23 +79,119 @@ func NewReqLogger(l Logger, c ReqConfig) ReqLogger {
 	if l == nil {
 		l = defaultLogger
 		if c.Level == LevelPrint {
-			c.Level = l.CopyConfig().Level
+			c.Level = l.GetLevel()
 		}
 		// 因为 defaultLogger 是通过全局函数调用的，会多加一层，但这里是直接调用 defaultLogger，所以需要减掉一层。
 		calldepth--
 	}
 
-	return &reqLogger{
+	lv := c.LevelVar
+	if lv == nil && c.InheritLevel {
+		if lv = levelVarOf(l); lv == nil {
+			c.Level = l.GetLevel()
+		}
+	}
+	if lv == nil {
+		lv = NewLevelVar(c.Level)
+	}
+
+	rl := &reqLogger{
 		ReqConfig: c,
 		Logger:    l,
 		calldepth: calldepth,
+		lv:        lv,
+		forks:     new(uint32),
+	}
+	rl.setTrace(c.Trace)
+	return rl
+}
+
+func (rl *reqLogger) setTrace(tc TraceContext) {
+	rl.Trace = tc
+	rl.traceID, rl.spanID = "", ""
+	if tc.IsValid() {
+		rl.traceID, rl.spanID = tc.TraceID.String(), tc.SpanID.String()
+	}
+}
+
+// withLogger returns a copy of rl with the underlying Logger l.
+func (rl *reqLogger) withLogger(l Logger) *reqLogger {
+	nrl := *rl
+	nrl.Logger = l
+	return &nrl
+}
+
+// Output writes the log to the underlying Logger, with the trace context of rl.
+func (rl *reqLogger) Output(lvl Level, calldepth int, reqID, s string) error {
+	return outputTrace(rl.Logger, lvl, calldepth+1, reqID, rl.traceID, rl.spanID, s)
+}
+
+// outputTrace implements traceOutputter, the trace ids of the outer ReqLogger are used.
+func (rl *reqLogger) outputTrace(lvl Level, calldepth int, reqID, traceID, spanID, s string) error {
+	return outputTrace(rl.Logger, lvl, calldepth+1, reqID, traceID, spanID, s)
+}
+
+func (rl *reqLogger) StartSpan() ReqLogger {
+	nrl := *rl
+	nrl.setTrace(rl.Trace.NewChild())
+	return &nrl
+}
+
+func (rl *reqLogger) Fork() ReqLogger {
+	n := strconv.FormatUint(uint64(atomic.AddUint32(rl.forks, 1)), 10)
+	nrl := *rl
+	if rl.ReqID != "" {
+		nrl.ReqID = rl.ReqID + "." + n
+	} else {
+		nrl.ReqID = n
 	}
+	nrl.forks = new(uint32)
+	return &nrl
 }
 
 func (rl *reqLogger) RequestConfig() *ReqConfig {
 	return &rl.ReqConfig
 }
 
+// GetLevel returns the level of rl, which is the level of the underlying Logger
+// only if they share a LevelVar, see ReqConfig.LevelVar and ReqConfig.InheritLevel.
+func (rl *reqLogger) GetLevel() Level {
+	return rl.lv.GetLevel()
+}
+
+// SetLevel changes the level of rl, which changes the level of the underlying Logger
+// only if they share a LevelVar, see ReqConfig.LevelVar and ReqConfig.InheritLevel.
+func (rl *reqLogger) SetLevel(lvl Level) {
+	rl.lv.SetLevel(lvl)
+}
+
+func (rl *reqLogger) levelVar() *LevelVar {
+	return rl.lv
+}
+
+// WriteEntry implements EntryWriter, the request id and trace ids of rl are used if e has none.
+func (rl *reqLogger) WriteEntry(e *Entry) error {
+	if (e.ReqID == "" && rl.ReqID != "") || (e.TraceID == "" && rl.traceID != "") {
+		ee := *e
+		if ee.ReqID == "" {
+			ee.ReqID = rl.ReqID
+		}
+		if ee.TraceID == "" {
+			ee.TraceID, ee.SpanID = rl.traceID, rl.spanID
+		}
+		e = &ee
+	}
+	return writeEntry(rl.Logger, rl.calldepth, e)
+}
+
+func (rl *reqLogger) With(keyvals ...interface{}) Logger {
+	return rl.withLogger(rl.Logger.With(keyvals...))
+}
+
+func (rl *reqLogger) WithFields(fields Fields) Logger {
+	return rl.withLogger(rl.Logger.WithFields(fields))
+}
+
 func (rl *reqLogger) Print(v ...interface{}) {
 	_ = rl.Output(LevelPrint, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
 }
@@ -78,100 +205,103 @@ func (rl *reqLogger) Println(v ...interface{}) {
 }
 
 func (rl *reqLogger) Debug(v ...interface{}) {
-	if rl.Level <= LevelDebug {
+	if rl.GetLevel() <= LevelDebug {
 		_ = rl.Output(LevelDebug, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
 	}
 }
 
 func (rl *reqLogger) Debugf(format string, v ...interface{}) {
-	if rl.Level <= LevelDebug {
+	if rl.GetLevel() <= LevelDebug {
 		_ = rl.Output(LevelDebug, rl.calldepth, rl.ReqID, fmt.Sprintf(format, v...))
 	}
 }
 
 func (rl *reqLogger) Debugln(v ...interface{}) {
-	if rl.Level <= LevelDebug {
+	if rl.GetLevel() <= LevelDebug {
 		_ = rl.Output(LevelDebug, rl.calldepth, rl.ReqID, fmt.Sprintln(v...))
 	}
 }
 
 func (rl *reqLogger) Info(v ...interface{}) {
-	if rl.Level <= LevelInfo {
+	if rl.GetLevel() <= LevelInfo {
 		_ = rl.Output(LevelInfo, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
 	}
 }
 
 func (rl *reqLogger) Infof(format string, v ...interface{}) {
-	if rl.Level <= LevelInfo {
+	if rl.GetLevel() <= LevelInfo {
 		_ = rl.Output(LevelInfo, rl.calldepth, rl.ReqID, fmt.Sprintf(format, v...))
 	}
 }
 
 func (rl *reqLogger) Infoln(v ...interface{}) {
-	if rl.Level <= LevelInfo {
+	if rl.GetLevel() <= LevelInfo {
 		_ = rl.Output(LevelInfo, rl.calldepth, rl.ReqID, fmt.Sprintln(v...))
 	}
 }
 
 func (rl *reqLogger) Warn(v ...interface{}) {
-	if rl.Level <= LevelWarn {
+	if rl.GetLevel() <= LevelWarn {
 		_ = rl.Output(LevelWarn, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
 	}
 }
 
 func (rl *reqLogger) Warnf(format string, v ...interface{}) {
-	if rl.Level <= LevelWarn {
+	if rl.GetLevel() <= LevelWarn {
 		_ = rl.Output(LevelWarn, rl.calldepth, rl.ReqID, fmt.Sprintf(format, v...))
 	}
 }
 
 func (rl *reqLogger) Warnln(v ...interface{}) {
-	if rl.Level <= LevelWarn {
+	if rl.GetLevel() <= LevelWarn {
 		_ = rl.Output(LevelWarn, rl.calldepth, rl.ReqID, fmt.Sprintln(v...))
 	}
 }
 
 func (rl *reqLogger) Error(v ...interface{}) {
-	if rl.Level <= LevelError {
+	if rl.GetLevel() <= LevelError {
 		_ = rl.Output(LevelError, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
 	}
 }
 
 func (rl *reqLogger) Errorf(format string, v ...interface{}) {
-	if rl.Level <= LevelError {
+	if rl.GetLevel() <= LevelError {
 		_ = rl.Output(LevelError, rl.calldepth, rl.ReqID, fmt.Sprintf(format, v...))
 	}
 }
 
 func (rl *reqLogger) Errorln(v ...interface{}) {
-	if rl.Level <= LevelError {
+	if rl.GetLevel() <= LevelError {
 		_ = rl.Output(LevelError, rl.calldepth, rl.ReqID, fmt.Sprintln(v...))
 	}
 }
 
 func (rl *reqLogger) Fatal(v ...interface{}) {
-	if rl.Level <= LevelFatal {
+	if rl.GetLevel() <= LevelFatal {
 		_ = rl.Output(LevelFatal, rl.calldepth, rl.ReqID, fmt.Sprint(v...))
+		_ = rl.Close()
 		osExit(1)
 	}
 }
 
 func (rl *reqLogger) Fatalf(format string, v ...interface{}) {
-	if rl.Level <= LevelFatal {
+	if rl.GetLevel() <= LevelFatal {
 		_ = rl.Output(LevelFatal, rl.calldepth, rl.ReqID, fmt.Sprintf(format, v...))
+		_ = rl.Close()
 		osExit(1)
 	}
 }
 
 func (rl *reqLogger) Fatalln(v ...interface{}) {
-	if rl.Level <= LevelFatal {
+	if rl.GetLevel() <= LevelFatal {
 		_ = rl.Output(LevelFatal, rl.calldepth, rl.ReqID, fmt.Sprintln(v...))
+		_ = rl.Close()
 		osExit(1)
 	}
 }
 
 func (rl *reqLogger) Panic(v ...interface{}) {
-	if rl.Level <= LevelPanic {
+	if rl.GetLevel() <= LevelPanic {
 		s := fmt.Sprint(v...)
 		_ = rl.Output(LevelPanic, rl.calldepth, rl.ReqID, s)
 		panic(s)
@@ -179,7 +309,7 @@ func (rl *reqLogger) Panic(v ...interface{}) {
 }
 
 func (rl *reqLogger) Panicf(format string, v ...interface{}) {
-	if rl.Level <= LevelPanic {
+	if rl.GetLevel() <= LevelPanic {
 		s := fmt.Sprintf(format, v...)
 		_ = rl.Output(LevelPanic, rl.calldepth, rl.ReqID, s)
 		panic(s)
@@ -187,7 +317,7 @@ func (rl *reqLogger) Panicf(format string, v ...interface{}) {
 }
 
 func (rl *reqLogger) Panicln(v ...interface{}) {
-	if rl.Level <= LevelPanic {
+	if rl.GetLevel() <= LevelPanic {
 		s := fmt.Sprintln(v...)
 		_ = rl.Output(LevelPanic, rl.calldepth, rl.ReqID, s)
 		panic(s)
@@ -0,0 +1,273 @@
+package xlog
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// reqIDCounter is mixed into the request ids generated by CounterReqIDGen and HostReqIDGen.
+var reqIDCounter uint32
+
+// hostID is the hash of the host name, see HostHash.
+var hostID = func() [3]byte {
+	name, _ := os.Hostname()
+	return hostHash(name)
+}()
+
+// HostHash returns the hash of the host name in the request ids generated by
+// HostReqIDGen, which is ReqIDInfo.Host of the ids generated on the host.
+func HostHash(name string) string {
+	h := hostHash(name)
+	return string(appendHex(make([]byte, 0, 6), h[:]))
+}
+
+// hostHash is the first 3 bytes of the FNV-1a hash of name.
+func hostHash(name string) (id [3]byte) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	sum := h.Sum32()
+	id[0], id[1], id[2] = byte(sum>>24), byte(sum>>16), byte(sum>>8)
+	return
+}
+
+// CounterReqIDGen generates a request id of 20 characters, which is the URL-base64
+// of the pid, the current time in nanoseconds and a counter of 3 bytes, so the
+// ids generated in the same process are unique unless 16M ids are generated at
+// the same time, e.g. on the hosts with a coarse clock.
+func CounterReqIDGen() string {
+	var buf [15]byte
+	binary.LittleEndian.PutUint32(buf[:4], pid)
+	binary.LittleEndian.PutUint64(buf[4:12], uint64(time.Now().UnixNano()))
+	putUint24(buf[12:], atomic.AddUint32(&reqIDCounter, 1))
+	return base64.URLEncoding.EncodeToString(buf[:])
+}
+
+// HostReqIDGen generates a request id of 24 characters, which is the same as
+// CounterReqIDGen except it starts with the hash of the host name, so the ids
+// generated by the processes with the same pid on different hosts are different.
+func HostReqIDGen() string {
+	var buf [18]byte
+	copy(buf[:3], hostID[:])
+	binary.LittleEndian.PutUint32(buf[3:7], pid)
+	binary.LittleEndian.PutUint64(buf[7:15], uint64(time.Now().UnixNano()))
+	putUint24(buf[15:], atomic.AddUint32(&reqIDCounter, 1))
+	return base64.URLEncoding.EncodeToString(buf[:])
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// UUIDv4ReqIDGen generates a random UUID (version 4) as the request id:
+//
+//	0a6e7c1c-2f8e-4f6c-9b1d-5c3e8f9a2b7d
+func UUIDv4ReqIDGen() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return formatUUID(u)
+}
+
+// UUIDv7ReqIDGen generates a time-ordered UUID (version 7) as the request id,
+// which starts with the current time in milliseconds, followed by random bits:
+//
+//	019a3f2e-8c41-7b3a-9d2e-6f1c0a8b4e5d
+func UUIDv7ReqIDGen() string {
+	var u [16]byte
+	putUint48(u[:6], uint64(time.Now().UnixNano()/1e6))
+	_, _ = rand.Read(u[6:])
+	u[6] = u[6]&0x0f | 0x70 // version 7
+	u[8] = u[8]&0x3f | 0x80 // variant 10
+	return formatUUID(u)
+}
+
+func putUint48(b []byte, v uint64) {
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+func formatUUID(u [16]byte) string {
+	buf := make([]byte, 0, 36)
+	buf = appendHex(buf, u[:4])
+	buf = append(buf, '-')
+	buf = appendHex(buf, u[4:6])
+	buf = append(buf, '-')
+	buf = appendHex(buf, u[6:8])
+	buf = append(buf, '-')
+	buf = appendHex(buf, u[8:10])
+	buf = append(buf, '-')
+	buf = appendHex(buf, u[10:])
+	return string(buf)
+}
+
+// crockford is the Base32 alphabet of ULID.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDReqIDGen generates a ULID of 26 characters as the request id, which is
+// the Crockford's Base32 of the current time in milliseconds and 80 random bits:
+//
+//	01JAB3XKZ8T9W2Q4R6Y5M7N0PC
+func ULIDReqIDGen() string {
+	var u [16]byte
+	putUint48(u[:6], uint64(time.Now().UnixNano()/1e6))
+	_, _ = rand.Read(u[6:])
+	return encodeULID(u)
+}
+
+// encodeULID encodes the 128 bits of u as 26 characters of 5 bits, the first
+// character only has 3 bits.
+func encodeULID(u [16]byte) string {
+	hi := binary.BigEndian.Uint64(u[:8])
+	lo := binary.BigEndian.Uint64(u[8:])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(buf[:])
+}
+
+// ReqIDType is the generator of a request id.
+type ReqIDType string
+
+// The types of the request ids generated by the built-in generators.
+const (
+	ReqIDDefault ReqIDType = "default" // DefaultReqIDGen
+	ReqIDCounter ReqIDType = "counter" // CounterReqIDGen
+	ReqIDHost    ReqIDType = "host"    // HostReqIDGen
+	ReqIDUUIDv4  ReqIDType = "uuidv4"  // UUIDv4ReqIDGen
+	ReqIDUUIDv7  ReqIDType = "uuidv7"  // UUIDv7ReqIDGen
+	ReqIDULID    ReqIDType = "ulid"    // ULIDReqIDGen
+)
+
+// ReqIDInfo is the information in a request id, see ParseReqID.
+type ReqIDInfo struct {
+	Type ReqIDType
+	// PID is the pid of the process generating the id, 0 if it is unknown.
+	PID uint32
+	// Time is when the id was generated, in nanoseconds for ReqIDDefault, ReqIDCounter
+	// and ReqIDHost, in milliseconds for ReqIDUUIDv7 and ReqIDULID, zero if it is unknown.
+	Time time.Time
+	// Counter is the counter of ReqIDCounter and ReqIDHost.
+	Counter uint32
+	// Host is the hash of the host name of ReqIDHost in hex, see HostHash.
+	Host string
+}
+
+// ParseReqID parses a request id generated by the built-in generators. The type
+// is inferred from the format of id, so an id of 16, 20 or 24 characters of
+// URL-base64 is always parsed as generated by DefaultReqIDGen, CounterReqIDGen
+// or HostReqIDGen, even if it comes from a client.
+// The UUIDs and ULIDs are parsed case-insensitively, and the sequence numbers
+// of ReqLogger.Fork like ".2.1" are ignored.
+func ParseReqID(id string) (ReqIDInfo, error) {
+	var info ReqIDInfo
+	id = trimForks(id)
+	switch {
+	case len(id) == 36 && id[8] == '-' && id[13] == '-' && id[18] == '-' && id[23] == '-':
+		var u [16]byte
+		if !decodeLowerHex(u[:], strings.ToLower(strings.Replace(id, "-", "", 4))) {
+			return info, fmt.Errorf("xlog: invalid uuid %q", id)
+		}
+		switch u[6] >> 4 {
+		case 4:
+			info.Type = ReqIDUUIDv4
+		case 7:
+			info.Type = ReqIDUUIDv7
+			info.Time = time.Unix(0, int64(uint48(u[:6]))*1e6)
+		default:
+			return info, fmt.Errorf("xlog: unsupported uuid version %d of %q", u[6]>>4, id)
+		}
+		return info, nil
+	case len(id) == 26:
+		u, ok := decodeULID(id)
+		if !ok {
+			return info, fmt.Errorf("xlog: invalid ulid %q", id)
+		}
+		info.Type = ReqIDULID
+		info.Time = time.Unix(0, int64(uint48(u[:6]))*1e6)
+		return info, nil
+	case len(id) == 16 || len(id) == 20 || len(id) == 24:
+		b, err := base64.URLEncoding.DecodeString(id)
+		if err != nil {
+			return info, fmt.Errorf("xlog: invalid request id %q: %v", id, err)
+		}
+		if len(b) == 18 {
+			info.Type = ReqIDHost
+			info.Host = string(appendHex(make([]byte, 0, 6), b[:3]))
+			b = b[3:]
+		} else if len(b) == 15 {
+			info.Type = ReqIDCounter
+		} else {
+			info.Type = ReqIDDefault
+		}
+		info.PID = binary.LittleEndian.Uint32(b[:4])
+		info.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(b[4:12])))
+		if len(b) == 15 {
+			info.Counter = uint32(b[12]) | uint32(b[13])<<8 | uint32(b[14])<<16
+		}
+		return info, nil
+	}
+	return info, fmt.Errorf("xlog: unknown request id %q", id)
+}
+
+// trimForks trims the sequence numbers of ReqLogger.Fork from id.
+func trimForks(id string) string {
+	i := len(id)
+	for i > 0 && '0' <= id[i-1] && id[i-1] <= '9' {
+		j := i - 1
+		for j > 0 && '0' <= id[j-1] && id[j-1] <= '9' {
+			j--
+		}
+		if j == 0 || id[j-1] != '.' {
+			break
+		}
+		i = j - 1
+	}
+	return id[:i]
+}
+
+func uint48(b []byte) uint64 {
+	var v uint64
+	for _, c := range b[:6] {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// decodeULID decodes a ULID case-insensitively, it reports false if s is not valid.
+func decodeULID(s string) (u [16]byte, ok bool) {
+	if len(s) != 26 {
+		return u, false
+	}
+	var hi, lo uint64
+	for i := 0; i < len(s); i++ {
+		c := strings.IndexByte(crockford, upper(s[i]))
+		if c < 0 || (i == 0 && c > 7) {
+			return u, false
+		}
+		hi = hi<<5 | lo>>59
+		lo = lo<<5 | uint64(c)
+	}
+	binary.BigEndian.PutUint64(u[:8], hi)
+	binary.BigEndian.PutUint64(u[8:], lo)
+	return u, true
+}
+
+func upper(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
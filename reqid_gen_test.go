@@ -0,0 +1,180 @@
+package xlog
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var reqIDGens = []struct {
+	name    string
+	gen     func() string
+	pattern string
+}{
+	{"Counter", CounterReqIDGen, `^[A-Za-z0-9_-]{20}$`},
+	{"Host", HostReqIDGen, `^[A-Za-z0-9_-]{24}$`},
+	{"UUIDv4", UUIDv4ReqIDGen, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	{"UUIDv7", UUIDv7ReqIDGen, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+	{"ULID", ULIDReqIDGen, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+}
+
+func TestReqIDGenFormat(t *testing.T) {
+	for _, g := range reqIDGens {
+		re := regexp.MustCompile(g.pattern)
+		for i := 0; i < 100; i++ {
+			if id := g.gen(); !re.MatchString(id) || !ValidReqID(id) {
+				t.Fatalf("%s: invalid id %q", g.name, id)
+			}
+		}
+	}
+}
+
+func TestReqIDGenUnique(t *testing.T) {
+	const workers = 8
+	n := 1 << 21 // 2M ids of each generator.
+	if testing.Short() || raceEnabled {
+		n = 1 << 16
+	}
+	for _, g := range reqIDGens {
+		var wg sync.WaitGroup
+		ids := make([][]string, workers)
+		for w := range ids {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				s := make([]string, n/workers)
+				for i := range s {
+					s[i] = g.gen()
+				}
+				ids[w] = s
+			}(w)
+		}
+		wg.Wait()
+		all := make([]string, 0, n)
+		for _, s := range ids {
+			all = append(all, s...)
+		}
+		sort.Strings(all)
+		for i := 1; i < len(all); i++ {
+			if all[i] == all[i-1] {
+				t.Fatalf("%s: duplicate id %q in %d ids", g.name, all[i], len(all))
+			}
+		}
+	}
+}
+
+func TestReqIDGenTimeOrdered(t *testing.T) {
+	for _, gen := range []func() string{UUIDv7ReqIDGen, ULIDReqIDGen} {
+		a := gen()
+		time.Sleep(2 * time.Millisecond)
+		if b := gen(); strings.Compare(a, b) >= 0 {
+			t.Fatalf("expect %q < %q", a, b)
+		}
+	}
+}
+
+func TestEncodeULID(t *testing.T) {
+	var u [16]byte
+	if got := encodeULID(u); got != "00000000000000000000000000" {
+		t.Fatalf("got %q", got)
+	}
+	for i := range u {
+		u[i] = 0xff
+	}
+	if got := encodeULID(u); got != "7ZZZZZZZZZZZZZZZZZZZZZZZZZ" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestParseReqID(t *testing.T) {
+	start := time.Now()
+	for _, c := range []struct {
+		gen     func() string
+		typ     ReqIDType
+		pid     bool
+		precise time.Duration
+	}{
+		{DefaultReqIDGen, ReqIDDefault, true, 0},
+		{CounterReqIDGen, ReqIDCounter, true, 0},
+		{HostReqIDGen, ReqIDHost, true, 0},
+		{UUIDv4ReqIDGen, ReqIDUUIDv4, false, -1},
+		{UUIDv7ReqIDGen, ReqIDUUIDv7, false, time.Millisecond},
+		{ULIDReqIDGen, ReqIDULID, false, time.Millisecond},
+	} {
+		id := c.gen()
+		info, err := ParseReqID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Type != c.typ {
+			t.Fatalf("%s: expect type %s, got %s", id, c.typ, info.Type)
+		}
+		if c.pid != (info.PID == pid) {
+			t.Fatalf("%s: unexpected pid %d", id, info.PID)
+		}
+		if c.precise < 0 {
+			if !info.Time.IsZero() {
+				t.Fatalf("%s: unexpected time %v", id, info.Time)
+			}
+		} else if info.Time.Before(start.Truncate(c.precise)) || info.Time.After(time.Now()) {
+			t.Fatalf("%s: unexpected time %v", id, info.Time)
+		}
+		if host, _ := os.Hostname(); (c.typ == ReqIDHost) != (info.Host == HostHash(host)) {
+			t.Fatalf("%s: unexpected host %q", id, info.Host)
+		}
+	}
+
+	info, err := ParseReqID("AQAAAAIAAAAAAAAAAwAA")
+	expect := ReqIDInfo{Type: ReqIDCounter, PID: 1, Time: time.Unix(0, 2), Counter: 3}
+	if err != nil || info != expect {
+		t.Fatalf("expect %+v, got %+v, %v", expect, info, err)
+	}
+	info, err = ParseReqID("019A3F2E-8C41-7B3A-9D2E-6F1C0A8B4E5D")
+	if err != nil || info.Type != ReqIDUUIDv7 || info.Time.UnixNano() != 0x019a3f2e8c41*1e6 {
+		t.Fatalf("got %+v, %v", info, err)
+	}
+	info, err = ParseReqID("AQAAAAIAAAAAAAAAAwAA.2.10")
+	if err != nil || info != expect {
+		t.Fatalf("expect %+v, got %+v, %v", expect, info, err)
+	}
+	info, err = ParseReqID("01arz3ndektsv4rrffq69g5fav")
+	if err != nil || info.Type != ReqIDULID || info.Time.UnixNano() != 1469922850259*1e6 {
+		t.Fatalf("got %+v, %v", info, err)
+	}
+
+	for _, id := range []string{
+		"",
+		"abc",
+		"AQAAAAIAAAAAAAA*",
+		"0a6e7c1c-2f8e-1f6c-9b1d-5c3e8f9a2b7d",
+		"81ARZ3NDEKTSV4RRFFQ69G5FAV",
+		"AQAAAAIAAAAAAAAAAwAA.",
+		"AQAAAAIAAAAAAAAAAwAA.a",
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU",
+	} {
+		if _, err := ParseReqID(id); err == nil {
+			t.Fatalf("%q: expect an error", id)
+		}
+	}
+}
+
+func BenchmarkReqIDGens(b *testing.B) {
+	bench := func(name string, gen func() string) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = gen()
+				}
+			})
+		})
+	}
+	bench("Default", DefaultReqIDGen)
+	for _, g := range reqIDGens {
+		bench(g.name, g.gen)
+	}
+}
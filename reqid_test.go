@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -56,6 +57,92 @@ func TestReqIDGen(t *testing.T) {
 	}
 }
 
+func TestReqLoggerSharedLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewWithWriter(buf, &Config{Level: LevelError})
+	rl := NewReqLogger(NewSampler(l, SamplerConfig{}), ReqConfig{ReqID: "id", InheritLevel: true})
+	own := NewReqLogger(l, ReqConfig{ReqID: "own", Level: LevelInfo})
+	all := NewReqLogger(l, ReqConfig{ReqID: "all"})
+	rl.Debug("disabled")
+	all.Debug("debug")
+	own.Info("info")
+	l.SetLevel(LevelDebug)
+	rl.Debug("debug")
+	own.Debug("disabled")
+	all.SetLevel(LevelError)
+	rl.SetLevel(LevelWarn)
+	l.Info("disabled")
+	all.Error("error")
+
+	expect := "[DEBU][all]debug\n[INFO][own]info\n[DEBU][id]debug\n[ERRO][all]error\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+
+	defer func(l Logger) { defaultLogger = l }(defaultLogger)
+	defaultLogger = l
+	rl = NewReqLogger(nil, ReqConfig{InheritLevel: true})
+	l.SetLevel(LevelFatal)
+	if rl.GetLevel() != LevelFatal {
+		t.Fatalf("the level of the default logger is not shared: %v", rl.GetLevel())
+	}
+	NewReqLogger(nil, ReqConfig{}).SetLevel(LevelDebug)
+	if l.GetLevel() != LevelFatal {
+		t.Fatalf("the level of the default logger is changed: %v", l.GetLevel())
+	}
+}
+
+func TestReqLoggerFork(t *testing.T) {
+	buf := new(bytes.Buffer)
+	rl := NewReqLogger(NewWithWriter(buf, nil), ReqConfig{ReqID: "abc", Level: LevelInfo})
+	l := rl.With("k", "v").(ReqLogger)
+	rl.Info("root")
+	c1 := l.Fork()
+	c2 := rl.Fork()
+	c2.Fork().Info("2.1")
+	c2.Info("2")
+	c1.Debug("disabled")
+	c1.Info("1")
+	c2.Fork().Fork().Warn("2.2.1")
+	rl.Info("root")
+
+	expect := "[INFO][abc]root\n" +
+		"[INFO][abc.2.1]2.1\n" +
+		"[INFO][abc.2]2\n" +
+		"[INFO][abc.1]1 k=v\n" +
+		"[WARN][abc.2.2.1]2.2.1\n" +
+		"[INFO][abc]root\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+	if id := rl.RequestConfig().ReqID; id != "abc" {
+		t.Fatalf("the parent is changed: %s", id)
+	}
+
+	// concurrent forks are numbered uniquely.
+	const n = 100
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = c1.Fork().RequestConfig().ReqID
+		}(i)
+	}
+	wg.Wait()
+	seen := make(map[string]bool, n)
+	for _, id := range ids {
+		if seen[id] || !strings.HasPrefix(id, "abc.1.") {
+			t.Fatalf("unexpected id %s in %v", id, ids)
+		}
+		seen[id] = true
+	}
+	if id := c1.Fork().RequestConfig().ReqID; id != "abc.1.101" {
+		t.Fatalf("got %s", id)
+	}
+}
+
 func BenchmarkReqLogger(b *testing.B) {
 	buf := new(bytes.Buffer)
 	rl := NewReqLogger(NewWithWriter(buf, nil), ReqConfig{Level: LevelDebug})
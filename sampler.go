@@ -0,0 +1,196 @@
+package xlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SampleRule is the sampling rule of a level.
+// In each interval, the first First logs of the same level and message are
+// written, and then every Thereafter-th one. If Thereafter is 0, the rest are suppressed.
+type SampleRule struct {
+	First      int
+	Thereafter int
+}
+
+// SamplerConfig is the config of NewSampler.
+type SamplerConfig struct {
+	// Rules are the rules of each level, the levels without a rule are not sampled.
+	// The Fatal and Panic logs are never sampled.
+	Rules map[Level]SampleRule
+	// Interval is the sampling interval, 1 second by default.
+	// It is also the interval to report the suppressed logs.
+	Interval time.Duration
+	// OnSuppressed is called with the number of suppressed logs of each level and message,
+	// about once per Interval if there are suppressed logs, and when the Logger is synced or closed.
+	// If it is nil, the number is written to the Logger as a warning log:
+	//
+	//	[WARN]xlog: logs are suppressed by sampler sampled_level=info sampled_msg=hello suppressed=998
+	OnSuppressed func(lvl Level, msg string, suppressed uint64)
+	Now          func() time.Time // returns the current time, time.Now by default.
+}
+
+// NewSampler wraps l with a sampler, to limit the logs of the same level and
+// message, e.g. logged by a hot path.
+// The sampling state is shared by the Loggers derived from the returned one by With.
+// The suppressed logs are reported by a background goroutine, which is stopped
+// by closing the returned Logger.
+//
+// If l is nil, the default logger is wrapped. If l is a ReqLogger created by
+// NewReqLogger, the returned Logger is a ReqLogger too, and the logs of all
+// requests are sampled together if the sampler is shared by NewReqLogger:
+//
+//	sampled := xlog.NewSampler(l, xlog.SamplerConfig{Rules: map[xlog.Level]xlog.SampleRule{
+//		xlog.LevelInfo: {First: 10, Thereafter: 100},
+//	}})
+//	rl := xlog.NewReqLogger(sampled, xlog.ReqConfig{})
+func NewSampler(l Logger, c SamplerConfig) Logger {
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	s := &sampler{
+		c:        c,
+		counters: make(map[sampleKey]*sampleCounter),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.nextReport = c.Now().Add(c.Interval)
+	fl := newFilterLogger(l, s)
+	tick, stop := newTicker(c.Interval)
+	go s.reportLoop(unwrapFilter(fl), tick, stop)
+	return fl
+}
+
+type sampleKey struct {
+	lvl Level
+	msg string
+}
+
+type sampleCounter struct {
+	start      time.Time // the start of the current interval.
+	n          int       // the number of logs in the current interval.
+	suppressed uint64    // the number of logs suppressed since the last report.
+}
+
+type sampleReport struct {
+	sampleKey
+	suppressed uint64
+}
+
+type sampler struct {
+	c SamplerConfig
+
+	mu         sync.Mutex
+	counters   map[sampleKey]*sampleCounter
+	nextReport time.Time
+
+	// stop stops reportLoop, which closes done when it returns.
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func (s *sampler) filter(l Logger, calldepth int, lvl Level, reqID, msg string) bool {
+	rule, ok := s.c.Rules[lvl]
+	if !ok {
+		return true
+	}
+	now := s.c.Now()
+	key := sampleKey{lvl: lvl, msg: msg}
+
+	s.mu.Lock()
+	var reports []sampleReport
+	if !now.Before(s.nextReport) {
+		reports = s.takeReports(now)
+		s.nextReport = now.Add(s.c.Interval)
+	}
+	c := s.counters[key]
+	if c == nil {
+		c = &sampleCounter{start: now}
+		s.counters[key] = c
+	} else if now.Sub(c.start) >= s.c.Interval {
+		c.start = now
+		c.n = 0
+	}
+	c.n++
+	ok = c.n <= rule.First || (rule.Thereafter > 0 && (c.n-rule.First)%rule.Thereafter == 0)
+	if !ok {
+		c.suppressed++
+	}
+	s.mu.Unlock()
+
+	s.report(l, calldepth+1, reports)
+	return ok
+}
+
+func (s *sampler) flush(l Logger) {
+	s.mu.Lock()
+	reports := s.takeReports(s.c.Now())
+	s.mu.Unlock()
+	s.report(l, 3, reports)
+}
+
+func (s *sampler) close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// reportLoop reports the suppressed logs to l on each tick, until the sampler is closed,
+// so that they are reported even if no more logs of the sampled levels are written.
+func (s *sampler) reportLoop(l Logger, tick <-chan time.Time, stopTicker func()) {
+	defer close(s.done)
+	defer stopTicker()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-tick:
+			now := s.c.Now()
+			s.mu.Lock()
+			var reports []sampleReport
+			if !now.Before(s.nextReport) {
+				reports = s.takeReports(now)
+				s.nextReport = now.Add(s.c.Interval)
+			}
+			s.mu.Unlock()
+			s.report(l, 1, reports)
+		}
+	}
+}
+
+// takeReports returns the suppressed counts since the last report, and
+// removes the counters whose interval is over. s.mu must be held.
+func (s *sampler) takeReports(now time.Time) []sampleReport {
+	var reports []sampleReport
+	for key, c := range s.counters {
+		if c.suppressed > 0 {
+			reports = append(reports, sampleReport{sampleKey: key, suppressed: c.suppressed})
+			c.suppressed = 0
+		}
+		if now.Sub(c.start) >= s.c.Interval {
+			delete(s.counters, key)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].lvl != reports[j].lvl {
+			return reports[i].lvl < reports[j].lvl
+		}
+		return reports[i].msg < reports[j].msg
+	})
+	return reports
+}
+
+func (s *sampler) report(l Logger, calldepth int, reports []sampleReport) {
+	for _, r := range reports {
+		if s.c.OnSuppressed != nil {
+			s.c.OnSuppressed(r.lvl, r.msg, r.suppressed)
+			continue
+		}
+		_ = l.With("sampled_level", r.lvl.String(), "sampled_msg", r.msg, "suppressed", r.suppressed).
+			Output(LevelWarn, calldepth+1, "", "xlog: logs are suppressed by sampler")
+	}
+}
@@ -0,0 +1,130 @@
+package xlog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampler(t *testing.T) {
+	var buf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)}
+	l := NewSampler(NewWithWriter(&buf, nil), SamplerConfig{
+		Rules: map[Level]SampleRule{LevelInfo: {First: 2, Thereafter: 3}},
+		Now:   clock.Now,
+	})
+	for i := 0; i < 10; i++ {
+		l.Info("hot")
+		l.Warn("not sampled")
+	}
+	l.Infoln("other")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var hot, warn int
+	for _, line := range lines {
+		switch line {
+		case "[INFO]hot":
+			hot++
+		case "[WARN]not sampled":
+			warn++
+		}
+	}
+	// the 1st, 2nd, 5th and 8th logs are written.
+	if hot != 4 || warn != 10 || lines[len(lines)-1] != "[INFO]other" {
+		t.Fatalf("hot %d, warn %d, logs:\n%s", hot, warn, buf.String())
+	}
+
+	// the next interval writes the report, and the counters are reset.
+	buf.Reset()
+	clock.Set(clock.Now().Add(time.Second))
+	l.Info("hot")
+	expect := "[WARN]xlog: logs are suppressed by sampler sampled_level=info sampled_msg=hot suppressed=6\n[INFO]hot\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
+
+func TestSamplerOnSuppressed(t *testing.T) {
+	var buf bytes.Buffer
+	var reports []string
+	l := NewSampler(NewWithWriter(&buf, nil), SamplerConfig{
+		Rules: map[Level]SampleRule{LevelDebug: {First: 1}, LevelError: {First: 1}},
+		OnSuppressed: func(lvl Level, msg string, suppressed uint64) {
+			reports = append(reports, fmt.Sprintf("%s %s %d", lvl, msg, suppressed))
+		},
+	})
+	l.SetLevel(LevelDebug)
+	l2 := l.With("k", "v") // shares the sampler.
+	for i := 0; i < 3; i++ {
+		l.Error("a")
+		l2.Error("a")
+		l.Debugf("b%d", 0)
+	}
+	if got := buf.String(); got != "[ERRO]a\n[DEBU]b0\n" {
+		t.Fatalf("got %q", got)
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(reports, ","); got != "debug b0 2,error a 5" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSamplerReqLogger(t *testing.T) {
+	var buf bytes.Buffer
+	rl := NewReqLogger(NewWithWriter(&buf, &Config{Flag: Lshortfile}), ReqConfig{ReqID: "id"})
+	l := NewSampler(rl, SamplerConfig{Rules: map[Level]SampleRule{LevelInfo: {First: 1}}})
+	if _, ok := l.(ReqLogger); !ok {
+		t.Fatalf("expect a ReqLogger, got %T", l)
+	}
+	l.Info("hi")
+	l.Info("hi")
+	l.With("k", "v").Info("hi")
+	if got := buf.String(); got != "[INFO][id]sampler_test.go:83: hi\n" {
+		t.Fatalf("got %q", got)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasSuffix(got, "[WARN]sampler_test.go:89: xlog: logs are suppressed by sampler sampled_level=info sampled_msg=hi suppressed=2\n") {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSamplerReportTicker(t *testing.T) {
+	defer func(f func(time.Duration) (<-chan time.Time, func())) { newTicker = f }(newTicker)
+	tick := make(chan time.Time)
+	newTicker = func(time.Duration) (<-chan time.Time, func()) { return tick, func() {} }
+
+	var buf bytes.Buffer
+	clock := &fakeClock{now: time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)}
+	l := NewSampler(NewWithWriter(&buf, nil), SamplerConfig{
+		Rules: map[Level]SampleRule{LevelInfo: {First: 1}},
+		Now:   clock.Now,
+	})
+	for i := 0; i < 5; i++ {
+		l.Info("hot")
+	}
+	tick <- time.Time{} // the Interval has not elapsed.
+	clock.Set(clock.Now().Add(time.Second))
+	l.Warn("not sampled")
+	tick <- time.Time{} // reports without any sampled logs.
+	tick <- time.Time{} // nothing to report, and waits for the last report.
+
+	expect := "[INFO]hot\n[WARN]not sampled\n" +
+		"[WARN]xlog: logs are suppressed by sampler sampled_level=info sampled_msg=hot suppressed=4\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tick <- time.Time{}:
+		t.Fatal("the ticker is not stopped by Close")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,181 @@
+//go:build go1.21
+
+package xlog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+)
+
+// SlogHandler is a slog.Handler which writes the records through a Logger,
+// so that the logs of log/slog have the same format as the others:
+//
+//	slog.SetDefault(slog.New(xlog.NewSlogHandler(l)))
+//
+// The levels of slog are mapped to Debug, Info, Warn and Error.
+// The attributes are written as the fields of the log, and a group is written
+// as a nested object by JSONEncoder, and as "group.key=value" by the others.
+// The request id and the trace context are taken from the ReqLogger in the
+// context, see NewContext.
+type SlogHandler struct {
+	l    Logger
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is a group or the attributes added by WithGroup or WithAttrs.
+type groupOrAttrs struct {
+	group  string
+	fields []Field
+}
+
+// NewSlogHandler creates a SlogHandler writing to l, the default logger is used if l is nil.
+// If l implements EntryWriter, which the Loggers of this package do, the time
+// and source of the records are written, otherwise they are the current time
+// and the caller of the slog.Logger.
+func NewSlogHandler(l Logger) *SlogHandler {
+	if l == nil {
+		l = defaultLogger
+	}
+	return &SlogHandler{l: l}
+}
+
+// SlogLevel converts the level of slog to Level.
+func SlogLevel(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return LevelDebug
+	case lvl < slog.LevelWarn:
+		return LevelInfo
+	case lvl < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+// Enabled implements slog.Handler, it reports whether the level is enabled by the Logger.
+func (h *SlogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return h.l.GetLevel() <= SlogLevel(lvl)
+}
+
+// Handle implements slog.Handler.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{
+		Time:    r.Time,
+		Level:   SlogLevel(r.Level),
+		Message: r.Message,
+		Fields:  h.fields(r),
+	}
+	if ctx != nil {
+		if rl, ok := FromContext(ctx); ok {
+			rc := rl.RequestConfig()
+			e.ReqID = rc.ReqID
+			if rc.Trace.IsValid() {
+				e.TraceID, e.SpanID = rc.Trace.TraceID.String(), rc.Trace.SpanID.String()
+			}
+		}
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		e.File, e.Line = frame.File, frame.Line
+	}
+	if ew, ok := h.l.(EntryWriter); ok {
+		return ew.WriteEntry(&e)
+	}
+	return writeEntry(h.l, callerDepth(r.PC)+1, &e)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var fields []Field
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	return h.with(groupOrAttrs{fields: fields})
+}
+
+// WithGroup implements slog.Handler.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *SlogHandler) with(goa groupOrAttrs) *SlogHandler {
+	goas := make([]groupOrAttrs, 0, len(h.goas)+1)
+	goas = append(goas, h.goas...)
+	goas = append(goas, goa)
+	return &SlogHandler{l: h.l, goas: goas}
+}
+
+// fields returns the fields of r and the handler, a group without fields is omitted.
+func (h *SlogHandler) fields(r slog.Record) []Field {
+	var fields []Field
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, a)
+		return true
+	})
+	// from the innermost group to the outermost.
+	for i := len(h.goas) - 1; i >= 0; i-- {
+		goa := h.goas[i]
+		if goa.group != "" {
+			if len(fields) > 0 {
+				fields = []Field{{Key: goa.group, Value: fields}}
+			}
+			continue
+		}
+		fs := make([]Field, 0, len(goa.fields)+len(fields))
+		fs = append(fs, goa.fields...)
+		fields = append(fs, fields...)
+	}
+	return fields
+}
+
+// appendAttr appends a to fields as slog.Handler requires: the value is resolved,
+// an empty attribute is ignored, and so is an empty group, and the attributes
+// of a group with an empty key are inlined.
+func appendAttr(fields []Field, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		return append(fields, Field{Key: a.Key, Value: a.Value.Any()})
+	}
+	attrs := a.Value.Group()
+	if a.Key == "" {
+		for _, ga := range attrs {
+			fields = appendAttr(fields, ga)
+		}
+		return fields
+	}
+	var group []Field
+	for _, ga := range attrs {
+		group = appendAttr(group, ga)
+	}
+	if len(group) == 0 {
+		return fields
+	}
+	return append(fields, Field{Key: a.Key, Value: group})
+}
+
+// callerDepth returns the depth of the frame of pc from the caller of callerDepth,
+// it is used if the Logger doesn't implement EntryWriter.
+func callerDepth(pc uintptr) int {
+	if pc == 0 {
+		return 0
+	}
+	var pcs [64]uintptr
+	n := runtime.Callers(2, pcs[:])
+	for i, p := range pcs[:n] {
+		if p == pc {
+			return i
+		}
+	}
+	return 0
+}
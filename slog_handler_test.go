@@ -0,0 +1,91 @@
+//go:build go1.21
+
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"testing/slogtest"
+	"time"
+)
+
+func TestSlogHandlerSlogtest(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithWriter(&buf, &Config{Encoder: JSONEncoder{}, TimeFormat: TimeRFC3339Nano})
+	results := func() []map[string]any {
+		var ms []map[string]any
+		for _, line := range bytes.Split(buf.Bytes(), []byte{'\n'}) {
+			if len(line) == 0 {
+				continue
+			}
+			var m map[string]any
+			if err := json.Unmarshal(line, &m); err != nil {
+				t.Fatalf("%s: %v", line, err)
+			}
+			ms = append(ms, m)
+		}
+		return ms
+	}
+	if err := slogtest.TestHandler(NewSlogHandler(l), results); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWithWriter(&buf, &Config{Prefix: "P ", Flag: Lshortfile, Level: LevelInfo})
+	sl := slog.New(NewSlogHandler(l.With("app", "x")))
+	ctx := NewContext(context.Background(), NewReqLogger(l, ReqConfig{ReqID: "id"}))
+
+	sl.Debug("debug")
+	sl.Info("info", "k", 1)
+	sl.WithGroup("g").With("a", "b").WarnContext(ctx, "warn", slog.Group("h", "c", 2))
+	sl.Log(ctx, slog.LevelError+4, "error", "d", time.Second)
+
+	expect := "P [INFO]slog_handler_test.go:44: info app=x k=1\n" +
+		"P [WARN][id]slog_handler_test.go:45: warn app=x g.a=b g.h.c=2\n" +
+		"P [ERRO][id]slog_handler_test.go:46: error app=x d=1s\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
+
+// outputLogger only implements Output of Logger, so it doesn't implement EntryWriter.
+type outputLogger struct {
+	Logger
+}
+
+func (l outputLogger) Output(lvl Level, calldepth int, reqID, s string) error {
+	return l.Logger.Output(lvl, calldepth+1, reqID, s)
+}
+
+func (l outputLogger) With(keyvals ...interface{}) Logger {
+	return outputLogger{l.Logger.With(keyvals...)}
+}
+
+func TestSlogHandlerOutput(t *testing.T) {
+	var buf bytes.Buffer
+	l := outputLogger{NewWithWriter(&buf, &Config{Flag: Lshortfile})}
+	sl := slog.New(NewSlogHandler(l))
+	sl.Info("info", "k", "v")
+	if got := buf.String(); got != "[INFO]slog_handler_test.go:73: info k=v\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSlogHandlerWrappers(t *testing.T) {
+	var buf bytes.Buffer
+	rl := NewReqLogger(NewWithWriter(&buf, nil), ReqConfig{ReqID: "id"})
+	sl := slog.New(NewSlogHandler(NewDedup(rl, DedupConfig{})))
+	for i := 0; i < 3; i++ {
+		sl.Error("failed")
+	}
+	sl.Info("ok")
+	expect := "[ERRO][id]failed\n[ERRO][id]last message repeated 2 times\n[INFO][id]ok\n"
+	if got := buf.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
@@ -26,130 +26,269 @@ type Config struct {
 	Flag          int
 	BaseCalldepth int
 	Level         Level
+	LevelVar      *LevelVar // if it is not nil, it is used instead of Level, and can be shared with other Loggers.
 	ForceColors   bool
 	InitBufSize   int
+	Encoder       Encoder // if it is nil, TextEncoder is used.
+	// TimeFormat is the layout of the time, see TimeRFC3339 and so on.
+	// If it is empty, the time is printed according to Flag.
+	TimeFormat string
+	// TimeLocation is the time zone of the time.
+	// If it is nil, UTC is used if LUTC is set, otherwise the local time zone.
+	TimeLocation *time.Location
+	// Hooks are fired before the log is written.
+	Hooks []Hook
+	// ErrorHandler handles the errors of Hooks. If it is nil, the errors are printed to os.Stderr.
+	ErrorHandler func(err error)
+	// Async makes the Logger write through an AsyncWriter if it is not nil.
+	// The Fatal and Panic logs wait for the queue to be written.
+	Async *AsyncConfig
 }
 
 // logger is the default implementation of the Logger interface.
 type logger struct {
-	wmut    sync.Mutex
-	w       io.Writer
-	bufPool sync.Pool
+	*core
 	Config
+	fields []Field
 }
 
-func newLogger(w io.Writer, c *Config) *logger {
-	if c == nil {
-		c = &Config{}
-	}
+// core is the output state shared by a logger and the loggers derived from it by With.
+type core struct {
+	sinks   []*sink
+	bufPool sync.Pool
+	tf      *timeFormatter
+	lv      *LevelVar
+	hooks   *levelHooks
+	onError func(error)
+
+	// stopReport stops reportLoop, which closes reportDone when it returns.
+	stopReport     chan struct{}
+	reportDone     chan struct{}
+	stopReportOnce sync.Once
+}
+
+func newLogger(c *Config, sinks []Sink) *logger {
 	if c.InitBufSize < 0 {
 		c.InitBufSize = 0
 	}
-	bufPool := sync.Pool{New: func() interface{} {
-		return make([]byte, 0, c.InitBufSize)
-	}}
-	return &logger{
-		w:       w,
-		bufPool: bufPool,
-		Config:  *c,
+	initBufSize := c.InitBufSize
+	co := &core{tf: newTimeFormatter(c.Flag, c.TimeFormat, c.TimeLocation), lv: c.LevelVar}
+	for _, s := range sinks {
+		co.sinks = append(co.sinks, newSink(s, c))
+	}
+	if co.lv == nil {
+		co.lv = NewLevelVar(c.Level)
+	}
+	co.hooks = newLevelHooks(c.Hooks)
+	co.onError = c.ErrorHandler
+	if co.onError == nil {
+		co.onError = defaultErrorHandler
+	}
+	co.bufPool.New = func() interface{} {
+		return make([]byte, 0, initBufSize)
+	}
+	l := &logger{
+		core:   co,
+		Config: *c,
 	}
+	var interval time.Duration
+	for _, sk := range co.sinks {
+		if sk.async != nil && (interval == 0 || sk.async.c.ReportInterval < interval) {
+			interval = sk.async.c.ReportInterval
+		}
+	}
+	if interval > 0 {
+		co.stopReport = make(chan struct{})
+		co.reportDone = make(chan struct{})
+		tick, stop := newTicker(interval)
+		go l.reportLoop(tick, stop)
+	}
+	return l
 }
 
 func (l *logger) Output(lvl Level, calldepth int, reqID, s string) error {
+	return l.outputTrace(lvl, calldepth+1, reqID, "", "", s)
+}
+
+// outputTrace implements traceOutputter.
+func (l *logger) outputTrace(lvl Level, calldepth int, reqID, traceID, spanID, s string) error {
 	now := time.Now() // get this early.
 
-	var file string
-	var line int
+	e := entryPool.Get().(*Entry)
+	e.Time = now
+	e.Level = lvl
+	e.Flag = l.Flag
+	e.Prefix = l.Prefix
+	e.ReqID = reqID
+	e.TraceID = traceID
+	e.SpanID = spanID
+	e.Fields = l.fields
+	e.ForceColors = l.ForceColors
+	e.tf = l.tf
 	if l.Flag&(Lshortfile|Llongfile) != 0 {
 		calldepth += l.BaseCalldepth
 		var ok bool
-		_, file, line, ok = runtime.Caller(calldepth)
+		_, e.File, e.Line, ok = runtime.Caller(calldepth)
 		if !ok {
-			file = "???"
-			line = 0
+			e.File = "???"
+			e.Line = 0
 		}
 	}
+	// The encoder adds the trailing newline.
+	if len(s) > 0 && s[len(s)-1] == '\n' {
+		s = s[:len(s)-1]
+	}
+	e.Message = s
+	return l.output(e)
+}
 
-	buf := l.bufPool.Get().([]byte)
-	buf = buf[:0]
-	// add color to header.
-	if l.ForceColors {
-		buf = append(buf, lvl.Color()...)
-	}
-	l.formatHeader(lvl, &buf, now, file, line, reqID)
-	// clear color.
-	if l.ForceColors {
-		buf = append(buf, "\x1b[0m"...)
-	}
-	buf = append(buf, s...)
-	// If there is no newline at the end, add it.
-	if len(s) == 0 || s[len(s)-1] != '\n' {
-		buf = append(buf, '\n')
-	}
-	l.wmut.Lock()
-	_, err := l.w.Write(buf)
-	l.wmut.Unlock()
-	l.bufPool.Put(buf)
-	return err
+// EntryWriter writes an Entry built by the caller, e.g. SlogHandler, which has
+// its own time, caller and fields. The Loggers of this package implement it.
+type EntryWriter interface {
+	// WriteEntry writes e. The Time, Level, ReqID, TraceID, SpanID, File, Line,
+	// Message and Fields of e are set by the caller, the others are set by the Logger, and the
+	// fields of the Logger are written before e.Fields. e is not retained.
+	WriteEntry(e *Entry) error
+}
+
+// WriteEntry implements EntryWriter.
+func (l *logger) WriteEntry(e *Entry) error {
+	ee := entryPool.Get().(*Entry)
+	ee.Time = e.Time
+	ee.Level = e.Level
+	ee.Flag = l.Flag
+	ee.Prefix = l.Prefix
+	ee.ReqID = e.ReqID
+	ee.TraceID = e.TraceID
+	ee.SpanID = e.SpanID
+	ee.File = e.File
+	ee.Line = e.Line
+	ee.Fields = e.Fields
+	if len(l.fields) > 0 {
+		ee.Fields = append(l.fields[:len(l.fields):len(l.fields)], e.Fields...)
+	}
+	ee.ForceColors = l.ForceColors
+	ee.tf = l.tf
+	ee.Message = e.Message
+	if n := len(ee.Message); n > 0 && ee.Message[n-1] == '\n' {
+		ee.Message = ee.Message[:n-1]
+	}
+	return l.output(ee)
 }
 
-// formatHeader writes log header to buf in following order:
-//   * l.prefix (if it's not blank),
-//   * date and/or time (if corresponding flags are provided),
-//   * level
-//   * reqID
-//   * file and line number (if corresponding flags are provided).
-func (l *logger) formatHeader(lvl Level, buf *[]byte, t time.Time, file string, line int, reqID string) {
-	*buf = append(*buf, l.Prefix...)
-	if l.Flag&(Ldate|Ltime|Lmicroseconds) != 0 {
-		if l.Flag&LUTC != 0 {
-			t = t.UTC()
+// writeEntry writes e to l by WriteEntry if l implements EntryWriter.
+// Otherwise, it is written by Output with the caller at calldepth, and the time
+// and caller of e are lost.
+func writeEntry(l Logger, calldepth int, e *Entry) error {
+	if ew, ok := l.(EntryWriter); ok {
+		return ew.WriteEntry(e)
+	}
+	if len(e.Fields) > 0 {
+		keyvals := make([]interface{}, 0, 2*len(e.Fields))
+		for _, f := range e.Fields {
+			keyvals = append(keyvals, f.Key, f.Value)
 		}
-		if l.Flag&Ldate != 0 {
-			year, month, day := t.Date()
-			itoa(buf, year, 4)
-			*buf = append(*buf, '/')
-			itoa(buf, int(month), 2)
-			*buf = append(*buf, '/')
-			itoa(buf, day, 2)
-			*buf = append(*buf, ' ')
+		l = l.With(keyvals...)
+	}
+	return l.Output(e.Level, calldepth+1, e.ReqID, e.Message)
+}
+
+// output fires the hooks and writes e to the sinks, then puts e back to the pool.
+func (l *logger) output(e *Entry) error {
+	l.hooks.fire(e, l.onError)
+
+	var err error
+	for _, sk := range l.sinks {
+		if !sk.enabled(e.Level) {
+			continue
 		}
-		if l.Flag&(Ltime|Lmicroseconds) != 0 {
-			hour, min, sec := t.Clock()
-			itoa(buf, hour, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, min, 2)
-			*buf = append(*buf, ':')
-			itoa(buf, sec, 2)
-			if l.Flag&Lmicroseconds != 0 {
-				*buf = append(*buf, '.')
-				itoa(buf, t.Nanosecond()/1e3, 6)
-			}
-			*buf = append(*buf, ' ')
+		e.ForceColors = sk.forceColors
+		if err2 := l.writeSink(sk, e); err == nil {
+			err = err2
 		}
 	}
+	*e = Entry{}
+	entryPool.Put(e)
+	return err
+}
 
-	// log level
-	*buf = append(*buf, lvl.LogStr()...)
+// writeSink writes e to sk, and reports the logs dropped by its AsyncWriter.
+func (l *logger) writeSink(sk *sink, e *Entry) error {
+	w := sk.w
+	if sk.async != nil {
+		if n := sk.async.takeDropped(e.Time, false); n > 0 {
+			// Report it with the time and caller of the current log.
+			report := *e
+			l.writeDropped(sk, &report, n)
+		}
+		if e.Level >= LevelFatal {
+			// The program may exit after it, so wait for it to be written.
+			w = (*asyncSyncWriter)(sk.async)
+		}
+	}
+	return l.write(sk, w, e)
+}
+
+// writeDropped writes the report of n logs dropped by the AsyncWriter of sk, e
+// has the time and caller of the report.
+func (l *logger) writeDropped(sk *sink, e *Entry, n uint64) {
+	e.Level = LevelWarn
+	e.Message = "xlog: logs are dropped by AsyncWriter"
+	e.Fields = []Field{{Key: "dropped", Value: n}}
+	_ = l.write(sk, (*asyncReportWriter)(sk.async), e)
+}
 
-	// request id
-	if reqID != "" {
-		*buf = append(*buf, '[')
-		*buf = append(*buf, reqID...)
-		*buf = append(*buf, ']')
+// reportDropped reports the logs dropped by the AsyncWriter of sk, if the
+// ReportInterval has elapsed since the last report or force is set.
+func (l *logger) reportDropped(sk *sink, now time.Time, force bool) {
+	if sk.async == nil {
+		return
 	}
+	if n := sk.async.takeDropped(now, force); n > 0 {
+		e := Entry{
+			Time:        now,
+			Flag:        l.Flag &^ (Lshortfile | Llongfile), // there is no caller.
+			Prefix:      l.Prefix,
+			ForceColors: sk.forceColors,
+			tf:          l.tf,
+		}
+		l.writeDropped(sk, &e, n)
+	}
+}
 
-	if l.Flag&(Lshortfile|Llongfile) != 0 {
-		if l.Flag&Lshortfile != 0 {
-			file = shortFile(file)
+var newTicker = func(d time.Duration) (<-chan time.Time, func()) { // for testing
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// reportLoop reports the dropped logs of the sinks on each tick, until the logger is closed.
+func (l *logger) reportLoop(tick <-chan time.Time, stopTicker func()) {
+	defer close(l.reportDone)
+	defer stopTicker()
+	for {
+		select {
+		case <-l.stopReport:
+			return
+		case now := <-tick:
+			for _, sk := range l.sinks {
+				l.reportDropped(sk, now, false)
+			}
 		}
-		*buf = append(*buf, file...)
-		*buf = append(*buf, ':')
-		itoa(buf, line, -1)
-		*buf = append(*buf, ": "...)
 	}
 }
 
+// write encodes e by the encoder of sk to the pooled buffer and writes it to w.
+func (l *logger) write(sk *sink, w io.Writer, e *Entry) error {
+	buf := l.bufPool.Get().([]byte)
+	buf = buf[:0]
+	sk.enc.Encode(&buf, e)
+	sk.mu.Lock()
+	_, err := w.Write(buf)
+	sk.mu.Unlock()
+	l.bufPool.Put(buf)
+	return err
+}
+
 func shortFile(f string) string {
 	short := f
 	for i := len(f) - 1; i > 0; i-- {
@@ -162,7 +301,78 @@ func shortFile(f string) string {
 }
 
 func (l *logger) CopyConfig() Config {
-	return l.Config
+	c := l.Config
+	c.Level = l.GetLevel()
+	return c
+}
+
+func (l *logger) GetLevel() Level {
+	return l.lv.GetLevel()
+}
+
+func (l *logger) SetLevel(lvl Level) {
+	l.lv.SetLevel(lvl)
+}
+
+func (l *logger) levelVar() *LevelVar {
+	return l.lv
+}
+
+// Sync reports the logs dropped by the AsyncWriters and flushes them, and syncs
+// the writers which have the method `Sync() error`.
+func (l *logger) Sync() error {
+	var err error
+	now := time.Now()
+	for _, sk := range l.sinks {
+		l.reportDropped(sk, now, true)
+		if err2 := sk.sync(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Close reports the logs dropped by the AsyncWriters, syncs and closes the
+// writers which implement io.Closer.
+// The loggers derived by With share the writers, so they are closed too.
+func (l *logger) Close() error {
+	if l.stopReport != nil {
+		l.stopReportOnce.Do(func() { close(l.stopReport) })
+		<-l.reportDone
+	}
+	var err error
+	now := time.Now()
+	for _, sk := range l.sinks {
+		l.reportDropped(sk, now, true)
+		if err2 := sk.close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+func (l *logger) With(keyvals ...interface{}) Logger {
+	return l.withFields(keyvalsToFields(keyvals))
+}
+
+func (l *logger) WithFields(fields Fields) Logger {
+	return l.withFields(fields.sorted())
+}
+
+// withFields returns a logger sharing l.core, with fs appended to l.fields.
+func (l *logger) withFields(fs []Field) *logger {
+	if len(fs) == 0 {
+		return l
+	}
+	n := len(l.fields) + len(fs)
+	fields := make([]Field, 0, n)
+	fields = append(fields, l.fields...)
+	fields = append(fields, fs...)
+	return &logger{
+		core:   l.core,
+		Config: l.Config,
+		fields: fields,
+	}
 }
 
 func (l *logger) Print(v ...interface{}) {
@@ -178,73 +388,73 @@ func (l *logger) Println(v ...interface{}) {
 }
 
 func (l *logger) Debug(v ...interface{}) {
-	if l.Level <= LevelDebug {
+	if l.GetLevel() <= LevelDebug {
 		_ = l.Output(LevelDebug, 2, "", fmt.Sprint(v...))
 	}
 }
 
 func (l *logger) Debugf(format string, v ...interface{}) {
-	if l.Level <= LevelDebug {
+	if l.GetLevel() <= LevelDebug {
 		_ = l.Output(LevelDebug, 2, "", fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *logger) Debugln(v ...interface{}) {
-	if l.Level <= LevelDebug {
+	if l.GetLevel() <= LevelDebug {
 		_ = l.Output(LevelDebug, 2, "", fmt.Sprintln(v...))
 	}
 }
 
 func (l *logger) Info(v ...interface{}) {
-	if l.Level <= LevelInfo {
+	if l.GetLevel() <= LevelInfo {
 		_ = l.Output(LevelInfo, 2, "", fmt.Sprint(v...))
 	}
 }
 
 func (l *logger) Infof(format string, v ...interface{}) {
-	if l.Level <= LevelInfo {
+	if l.GetLevel() <= LevelInfo {
 		_ = l.Output(LevelInfo, 2, "", fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *logger) Infoln(v ...interface{}) {
-	if l.Level <= LevelInfo {
+	if l.GetLevel() <= LevelInfo {
 		_ = l.Output(LevelInfo, 2, "", fmt.Sprintln(v...))
 	}
 }
 
 func (l *logger) Warn(v ...interface{}) {
-	if l.Level <= LevelWarn {
+	if l.GetLevel() <= LevelWarn {
 		_ = l.Output(LevelWarn, 2, "", fmt.Sprint(v...))
 	}
 }
 
 func (l *logger) Warnf(format string, v ...interface{}) {
-	if l.Level <= LevelWarn {
+	if l.GetLevel() <= LevelWarn {
 		_ = l.Output(LevelWarn, 2, "", fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *logger) Warnln(v ...interface{}) {
-	if l.Level <= LevelWarn {
+	if l.GetLevel() <= LevelWarn {
 		_ = l.Output(LevelWarn, 2, "", fmt.Sprintln(v...))
 	}
 }
 
 func (l *logger) Error(v ...interface{}) {
-	if l.Level <= LevelError {
+	if l.GetLevel() <= LevelError {
 		_ = l.Output(LevelError, 2, "", fmt.Sprint(v...))
 	}
 }
 
 func (l *logger) Errorf(format string, v ...interface{}) {
-	if l.Level <= LevelError {
+	if l.GetLevel() <= LevelError {
 		_ = l.Output(LevelError, 2, "", fmt.Sprintf(format, v...))
 	}
 }
 
 func (l *logger) Errorln(v ...interface{}) {
-	if l.Level <= LevelError {
+	if l.GetLevel() <= LevelError {
 		_ = l.Output(LevelError, 2, "", fmt.Sprintln(v...))
 	}
 }
@@ -252,28 +462,31 @@ func (l *logger) Errorln(v ...interface{}) {
 var osExit = os.Exit // for testing
 
 func (l *logger) Fatal(v ...interface{}) {
-	if l.Level <= LevelFatal {
+	if l.GetLevel() <= LevelFatal {
 		_ = l.Output(LevelFatal, 2, "", fmt.Sprint(v...))
+		_ = l.Close()
 		osExit(1)
 	}
 }
 
 func (l *logger) Fatalf(format string, v ...interface{}) {
-	if l.Level <= LevelFatal {
+	if l.GetLevel() <= LevelFatal {
 		_ = l.Output(LevelFatal, 2, "", fmt.Sprintf(format, v...))
+		_ = l.Close()
 		osExit(1)
 	}
 }
 
 func (l *logger) Fatalln(v ...interface{}) {
-	if l.Level <= LevelFatal {
+	if l.GetLevel() <= LevelFatal {
 		_ = l.Output(LevelFatal, 2, "", fmt.Sprintln(v...))
+		_ = l.Close()
 		osExit(1)
 	}
 }
 
 func (l *logger) Panic(v ...interface{}) {
-	if l.Level <= LevelPanic {
+	if l.GetLevel() <= LevelPanic {
 		s := fmt.Sprint(v...)
 		_ = l.Output(LevelPanic, 2, "", s)
 		panic(s)
@@ -281,7 +494,7 @@ func (l *logger) Panic(v ...interface{}) {
 }
 
 func (l *logger) Panicf(format string, v ...interface{}) {
-	if l.Level <= LevelPanic {
+	if l.GetLevel() <= LevelPanic {
 		s := fmt.Sprintf(format, v...)
 		_ = l.Output(LevelPanic, 2, "", s)
 		panic(s)
@@ -289,7 +502,7 @@ func (l *logger) Panicf(format string, v ...interface{}) {
 }
 
 func (l *logger) Panicln(v ...interface{}) {
-	if l.Level <= LevelPanic {
+	if l.GetLevel() <= LevelPanic {
 		s := fmt.Sprintln(v...)
 		_ = l.Output(LevelPanic, 2, "", s)
 		panic(s)
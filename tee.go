@@ -0,0 +1,92 @@
+package xlog
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// Sink is an output of the Logger created by NewTee.
+type Sink struct {
+	Writer io.Writer // if it is nil, os.Stderr is used.
+	// Level is the min level written to Writer, Print logs are always written.
+	// It only filters the logs which pass the level of the Logger.
+	Level       Level
+	Encoder     Encoder // if it is nil, Config.Encoder is used, then TextEncoder.
+	ForceColors bool    // it is also set by Config.ForceColors.
+}
+
+// NewTee creates a Logger which writes every log to all sinks whose level is enabled.
+// The caller and the message are resolved only once for each log, and the hooks
+// are fired once. Config.Encoder only applies to the sinks without their own,
+// Config.ForceColors forces the colors of all sinks, and if Config.Async is set,
+// each sink writes through its own AsyncWriter.
+//
+// For example, writes the errors to os.Stderr as colored text, and all logs to a JSON file:
+//
+//	l := xlog.NewTee(&xlog.Config{Flag: xlog.LstdFlags},
+//		xlog.Sink{Writer: os.Stderr, Level: xlog.LevelError, ForceColors: true},
+//		xlog.Sink{Writer: fw, Encoder: xlog.JSONEncoder{}},
+//	)
+func NewTee(c *Config, sinks ...Sink) Logger {
+	if c == nil {
+		c = &Config{}
+	}
+	return newLogger(c, sinks)
+}
+
+// sink is the output state of a Sink.
+type sink struct {
+	mu          sync.Mutex // serializes the writes to w.
+	w           io.Writer
+	lvl         Level
+	enc         Encoder
+	forceColors bool
+	async       *AsyncWriter
+}
+
+func newSink(s Sink, c *Config) *sink {
+	sk := &sink{w: s.Writer, lvl: s.Level, enc: s.Encoder, forceColors: s.ForceColors || c.ForceColors}
+	if sk.w == nil {
+		sk.w = os.Stderr
+	}
+	if c.Async != nil {
+		sk.w = NewAsyncWriter(sk.w, *c.Async)
+	}
+	sk.async, _ = sk.w.(*AsyncWriter)
+	if sk.enc == nil {
+		sk.enc = c.Encoder
+	}
+	if sk.enc == nil {
+		sk.enc = TextEncoder{}
+	}
+	return sk
+}
+
+// enabled reports whether the logs of lvl are written to sk.
+func (sk *sink) enabled(lvl Level) bool {
+	return lvl == LevelPrint || lvl >= sk.lvl
+}
+
+func (sk *sink) sync() error {
+	if sk.async != nil {
+		return sk.async.Sync()
+	}
+	return syncWriter(sk.w)
+}
+
+func (sk *sink) close() error {
+	w := sk.w
+	var err error
+	if sk.async != nil {
+		err = sk.async.Close()
+		w = sk.async.w
+	}
+	if err2 := syncWriter(w); err == nil {
+		err = err2
+	}
+	if err2 := closeWriter(w); err == nil {
+		err = err2
+	}
+	return err
+}
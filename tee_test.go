@@ -0,0 +1,87 @@
+package xlog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestTee(t *testing.T) {
+	var text, js bytes.Buffer
+	hook := &testHook{levels: []Level{LevelError}}
+	l := NewTee(&Config{Flag: Lshortfile, Level: LevelInfo, Hooks: []Hook{hook}},
+		Sink{Writer: &text, Level: LevelError, ForceColors: true},
+		Sink{Writer: &js, Encoder: JSONEncoder{}},
+	)
+	l.Debug("debug")
+	l.Info("info")
+	l.With("k", 1).Error("error")
+	l.Print("print")
+
+	expect := "\x1b[31m[ERRO]tee_test.go:18: \x1b[0merror k=1 hooked=true\n" +
+		"\x1b[0mtee_test.go:19: \x1b[0mprint\n"
+	if got := text.String(); got != expect {
+		t.Errorf("text sink:\nexpect %q\ngot    %q", expect, got)
+	}
+	expect = `{"level":"info","caller":"tee_test.go:17","msg":"info"}` + "\n" +
+		`{"level":"error","caller":"tee_test.go:18","msg":"error","k":1,"hooked":true}` + "\n" +
+		`{"level":"print","caller":"tee_test.go:19","msg":"print"}` + "\n"
+	if got := js.String(); got != expect {
+		t.Errorf("json sink:\nexpect %q\ngot    %q", expect, got)
+	}
+	if hook.count != 1 {
+		t.Errorf("expect the hook fired once, got %d", hook.count)
+	}
+}
+
+func TestTeeConfigDefaults(t *testing.T) {
+	var a, b bytes.Buffer
+	l := NewTee(&Config{Encoder: LogfmtEncoder{}}, Sink{Writer: &a}, Sink{Writer: &b, Encoder: TextEncoder{}})
+	l.Info("hi")
+	if got := a.String(); got != "level=info msg=hi\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := b.String(); got != "[INFO]hi\n" {
+		t.Errorf("got %q", got)
+	}
+
+	a.Reset()
+	l = NewTee(&Config{ForceColors: true}, Sink{Writer: &a})
+	l.Info("hi")
+	if got := a.String(); got != "\x1b[34m[INFO]\x1b[0mhi\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+type errWriter struct{ err error }
+
+func (w errWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestTeeError(t *testing.T) {
+	var buf bytes.Buffer
+	errFailed := errors.New("failed")
+	l := NewTee(nil, Sink{Writer: errWriter{errFailed}}, Sink{Writer: &buf})
+	if err := l.Output(LevelInfo, 1, "", "hi"); err != errFailed {
+		t.Errorf("expect %v, got %v", errFailed, err)
+	}
+	// the error of a sink doesn't stop the others.
+	if got := buf.String(); got != "[INFO]hi\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestTeeClose(t *testing.T) {
+	var a, b syncCloser
+	l := NewTee(&Config{Async: &AsyncConfig{}}, Sink{Writer: &a}, Sink{Writer: &b, Level: LevelWarn})
+	l.Info("info")
+	l.Warn("warn")
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != "[INFO]info\n[WARN]warn\n" || a.closed != 1 {
+		t.Errorf("got %q, closed %d", a.String(), a.closed)
+	}
+	if b.String() != "[WARN]warn\n" || b.closed != 1 {
+		t.Errorf("got %q, closed %d", b.String(), b.closed)
+	}
+}
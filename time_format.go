@@ -0,0 +1,216 @@
+package xlog
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The time formats which can be set to Config.TimeFormat.
+// Besides these, any layout accepted by time.Format can be used.
+const (
+	TimeRFC3339     = time.RFC3339
+	TimeRFC3339Nano = time.RFC3339Nano
+	TimeMillis      = "2006/01/02 15:04:05.000"       // millisecond resolution: 2009/01/23 01:23:23.123
+	TimeNanos       = "2006/01/02 15:04:05.000000000" // nanosecond resolution: 2009/01/23 01:23:23.123123123
+	TimeUnix        = "unix"                          // seconds since the Unix epoch: 1232673803
+	TimeUnixMilli   = "unixmilli"                     // milliseconds since the Unix epoch: 1232673803123
+)
+
+// kinds of timeFormatter.
+const (
+	timeFlag = iota // according to Ldate, Ltime and Lmicroseconds.
+	timeRFC3339
+	timeRFC3339Nano
+	timeMillis
+	timeNanos
+	timeUnix
+	timeUnixMilli
+	timeLayout // any other layout, formatted by time.Format.
+)
+
+// timeFormatter formats the time of the log.
+// Except for timeLayout, the time is formatted by itoa, and the part up to
+// the second is cached so that it is only built once per second.
+type timeFormatter struct {
+	kind   int
+	flag   int
+	layout string
+	loc    *time.Location
+	// cacheable is false if the layout contains fractional seconds.
+	cacheable bool
+	cache     atomic.Value // *timeCache
+}
+
+type timeCache struct {
+	sec  int64
+	head []byte // the time up to the second.
+	zone []byte // the zone offset of RFC3339.
+}
+
+func newTimeFormatter(flag int, layout string, loc *time.Location) *timeFormatter {
+	if loc == nil {
+		if flag&LUTC != 0 {
+			loc = time.UTC
+		} else {
+			loc = time.Local
+		}
+	}
+	tf := &timeFormatter{flag: flag, layout: layout, loc: loc, cacheable: true}
+	switch layout {
+	case "":
+		tf.kind = timeFlag
+	case TimeRFC3339:
+		tf.kind = timeRFC3339
+	case TimeRFC3339Nano:
+		tf.kind = timeRFC3339Nano
+	case TimeMillis:
+		tf.kind = timeMillis
+	case TimeNanos:
+		tf.kind = timeNanos
+	case TimeUnix:
+		tf.kind = timeUnix
+	case TimeUnixMilli:
+		tf.kind = timeUnixMilli
+	default:
+		tf.kind = timeLayout
+		tf.cacheable = !hasFractionalSeconds(layout)
+	}
+	return tf
+}
+
+// hasFractionalSeconds reports whether layout contains fractional seconds like ".000" or ",999".
+func hasFractionalSeconds(layout string) bool {
+	for _, s := range []string{".0", ".9", ",0", ",9"} {
+		if strings.Contains(layout, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether the time should be printed.
+func (tf *timeFormatter) enabled() bool {
+	return tf.kind != timeFlag || tf.flag&(Ldate|Ltime|Lmicroseconds) != 0
+}
+
+// numeric reports whether the time is formatted as a number.
+func (tf *timeFormatter) numeric() bool {
+	return tf.kind == timeUnix || tf.kind == timeUnixMilli
+}
+
+func (tf *timeFormatter) append(buf *[]byte, t time.Time) {
+	switch tf.kind {
+	case timeUnix:
+		*buf = strconv.AppendInt(*buf, t.Unix(), 10)
+		return
+	case timeUnixMilli:
+		*buf = strconv.AppendInt(*buf, t.UnixNano()/1e6, 10)
+		return
+	}
+
+	t = t.In(tf.loc)
+	if tf.kind == timeLayout && !tf.cacheable {
+		*buf = t.AppendFormat(*buf, tf.layout)
+		return
+	}
+	sec := t.Unix()
+	c, _ := tf.cache.Load().(*timeCache)
+	if c == nil || c.sec != sec {
+		c = tf.newCache(t)
+		tf.cache.Store(c)
+	}
+	*buf = append(*buf, c.head...)
+
+	switch tf.kind {
+	case timeFlag:
+		if tf.flag&Lmicroseconds != 0 {
+			*buf = append(*buf, '.')
+			itoa(buf, t.Nanosecond()/1e3, 6)
+		}
+	case timeRFC3339Nano:
+		if ns := t.Nanosecond(); ns != 0 {
+			*buf = append(*buf, '.')
+			itoa(buf, ns, 9)
+			// trailing zeros are removed, as time.RFC3339Nano does.
+			n := len(*buf)
+			for (*buf)[n-1] == '0' {
+				n--
+			}
+			*buf = (*buf)[:n]
+		}
+	case timeMillis:
+		*buf = append(*buf, '.')
+		itoa(buf, t.Nanosecond()/1e6, 3)
+	case timeNanos:
+		*buf = append(*buf, '.')
+		itoa(buf, t.Nanosecond(), 9)
+	}
+	*buf = append(*buf, c.zone...)
+}
+
+func (tf *timeFormatter) newCache(t time.Time) *timeCache {
+	c := &timeCache{sec: t.Unix()}
+	switch tf.kind {
+	case timeFlag:
+		if tf.flag&Ldate != 0 {
+			appendDate(&c.head, t, '/')
+			if tf.flag&(Ltime|Lmicroseconds) != 0 {
+				c.head = append(c.head, ' ')
+			}
+		}
+		if tf.flag&(Ltime|Lmicroseconds) != 0 {
+			appendClock(&c.head, t)
+		}
+	case timeRFC3339, timeRFC3339Nano:
+		appendDate(&c.head, t, '-')
+		c.head = append(c.head, 'T')
+		appendClock(&c.head, t)
+		c.zone = t.AppendFormat(nil, "Z07:00")
+	case timeMillis, timeNanos:
+		appendDate(&c.head, t, '/')
+		c.head = append(c.head, ' ')
+		appendClock(&c.head, t)
+	case timeLayout:
+		c.head = t.AppendFormat(nil, tf.layout)
+	}
+	return c
+}
+
+func appendDate(buf *[]byte, t time.Time, sep byte) {
+	year, month, day := t.Date()
+	itoa(buf, year, 4)
+	*buf = append(*buf, sep)
+	itoa(buf, int(month), 2)
+	*buf = append(*buf, sep)
+	itoa(buf, day, 2)
+}
+
+func appendClock(buf *[]byte, t time.Time) {
+	hour, min, sec := t.Clock()
+	itoa(buf, hour, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, min, 2)
+	*buf = append(*buf, ':')
+	itoa(buf, sec, 2)
+}
+
+// HasTime reports whether the time of e should be printed, according to
+// the time settings of the Logger. A zero Time is never printed.
+func (e *Entry) HasTime() bool {
+	return !e.Time.IsZero() && e.timeFormatter().enabled()
+}
+
+// AppendTime writes the time of e to buf, according to the time settings of the Logger.
+func (e *Entry) AppendTime(buf *[]byte) {
+	e.timeFormatter().append(buf, e.Time)
+}
+
+func (e *Entry) timeFormatter() *timeFormatter {
+	if e.tf == nil {
+		// The Entry is not created by a Logger.
+		e.tf = newTimeFormatter(e.Flag, "", nil)
+	}
+	return e.tf
+}
@@ -0,0 +1,117 @@
+package xlog
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTimeFormatter(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	tm := time.Date(2009, 1, 23, 1, 23, 23, 123456700, loc)
+	cases := []struct {
+		flag   int
+		layout string
+		loc    *time.Location
+		want   string
+	}{
+		{Ldate, "", loc, "2009/01/23"},
+		{Ltime, "", loc, "01:23:23"},
+		{LstdFlags | Lmicroseconds, "", loc, "2009/01/23 01:23:23.123456"},
+		{LstdFlags | LUTC, "", nil, "2009/01/22 17:23:23"},
+		{0, TimeRFC3339, loc, tm.Format(time.RFC3339)},
+		{0, TimeRFC3339, time.UTC, tm.UTC().Format(time.RFC3339)},
+		{0, TimeRFC3339Nano, loc, tm.Format(time.RFC3339Nano)},
+		{0, TimeMillis, loc, "2009/01/23 01:23:23.123"},
+		{0, TimeNanos, loc, "2009/01/23 01:23:23.123456700"},
+		{0, TimeUnix, loc, strconv.FormatInt(tm.Unix(), 10)},
+		{0, TimeUnixMilli, loc, strconv.FormatInt(tm.UnixNano()/1e6, 10)},
+		{0, time.Kitchen, loc, tm.Format(time.Kitchen)},
+		{0, time.StampMicro, loc, tm.Format(time.StampMicro)},
+		{LUTC, time.Kitchen, nil, tm.UTC().Format(time.Kitchen)},
+	}
+	for _, c := range cases {
+		tf := newTimeFormatter(c.flag, c.layout, c.loc)
+		// twice, the second one hits the cache.
+		for i := 0; i < 2; i++ {
+			var buf []byte
+			tf.append(&buf, tm)
+			if string(buf) != c.want {
+				t.Errorf("flag %d, layout %q: got %q, want %q", c.flag, c.layout, buf, c.want)
+			}
+		}
+	}
+}
+
+func TestTimeFormatterCache(t *testing.T) {
+	tf := newTimeFormatter(0, TimeRFC3339Nano, time.UTC)
+	tm := time.Date(2009, 1, 23, 1, 23, 59, 999999999, time.UTC)
+	for i := 0; i < 3; i++ {
+		var buf []byte
+		tf.append(&buf, tm)
+		if want := tm.Format(time.RFC3339Nano); string(buf) != want {
+			t.Fatalf("got %q, want %q", buf, want)
+		}
+		tm = tm.Add(time.Nanosecond)
+	}
+
+	// nanoseconds are zero.
+	var buf []byte
+	tf.append(&buf, time.Date(2009, 1, 23, 1, 23, 23, 0, time.UTC))
+	if string(buf) != "2009-01-23T01:23:23Z" {
+		t.Fatalf("got %q", buf)
+	}
+}
+
+func TestConfigTimeFormat(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{TimeFormat: TimeUnix, Encoder: JSONEncoder{}})
+	before := time.Now().Unix()
+	l.Print("hello")
+	after := time.Now().Unix()
+
+	want1 := `{"time":` + strconv.FormatInt(before, 10) + `,"level":"print","msg":"hello"}` + "\n"
+	want2 := `{"time":` + strconv.FormatInt(after, 10) + `,"level":"print","msg":"hello"}` + "\n"
+	if b.String() != want1 && b.String() != want2 {
+		t.Fatalf("got %s, want %s", b.String(), want1)
+	}
+
+	b.Reset()
+	l = NewWithWriter(b, &Config{TimeFormat: TimeRFC3339, TimeLocation: time.UTC})
+	l.Print("hello")
+	if _, err := time.Parse(time.RFC3339, b.String()[:20]); err != nil || b.String()[19] != 'Z' {
+		t.Fatalf("wrong time: %q, %v", b.String(), err)
+	}
+
+	b.Reset()
+	l = NewWithWriter(b, &Config{TimeFormat: TimeMillis, Encoder: LogfmtEncoder{}})
+	l.Print("hello")
+	if b.String()[:4] != `ts="` || b.String()[27:29] != `" ` {
+		t.Fatalf("wrong time: %q", b.String())
+	}
+}
+
+func BenchmarkTimeFormatterFlag(b *testing.B) {
+	tf := newTimeFormatter(LstdFlags|Lmicroseconds, "", nil)
+	benchmarkTimeFormatter(b, tf)
+}
+
+func BenchmarkTimeFormatterRFC3339Nano(b *testing.B) {
+	tf := newTimeFormatter(0, TimeRFC3339Nano, nil)
+	benchmarkTimeFormatter(b, tf)
+}
+
+func BenchmarkTimeFormatterLayout(b *testing.B) {
+	tf := newTimeFormatter(0, time.RFC1123Z, nil)
+	benchmarkTimeFormatter(b, tf)
+}
+
+func benchmarkTimeFormatter(b *testing.B, tf *timeFormatter) {
+	buf := make([]byte, 0, 64)
+	now := time.Now()
+	for i := 0; i < b.N; i++ {
+		buf = buf[:0]
+		tf.append(&buf, now)
+	}
+}
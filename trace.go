@@ -0,0 +1,152 @@
+package xlog
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// HeaderTraceparent is the header of the W3C trace context.
+const HeaderTraceparent = "traceparent"
+
+// TraceID is the trace id of the W3C trace context.
+type TraceID [16]byte
+
+// IsValid reports whether id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// String returns id in lowercase hex.
+func (id TraceID) String() string { return string(appendHex(make([]byte, 0, 32), id[:])) }
+
+// SpanID is the span id, i.e. the parent id of traceparent, of the W3C trace context.
+type SpanID [8]byte
+
+// IsValid reports whether id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// String returns id in lowercase hex.
+func (id SpanID) String() string { return string(appendHex(make([]byte, 0, 16), id[:])) }
+
+// TraceContext is the W3C trace context carried by a ReqLogger, see
+// https://www.w3.org/TR/trace-context/. The zero value means there is no trace.
+type TraceContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// NewTraceContext starts a new trace with random ids.
+func NewTraceContext(sampled bool) TraceContext {
+	tc := TraceContext{Sampled: sampled}
+	_, _ = rand.Read(tc.TraceID[:])
+	_, _ = rand.Read(tc.SpanID[:])
+	return tc
+}
+
+// IsValid reports whether both the trace id and the span id are valid.
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID.IsValid() && tc.SpanID.IsValid()
+}
+
+// NewChild returns a child span of tc, which has the same trace id and a new span id.
+// If tc is not valid, a new trace is started.
+func (tc TraceContext) NewChild() TraceContext {
+	if !tc.TraceID.IsValid() {
+		return NewTraceContext(tc.Sampled)
+	}
+	_, _ = rand.Read(tc.SpanID[:])
+	return tc
+}
+
+// Traceparent returns tc as the value of the traceparent header:
+//
+//	00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (tc TraceContext) Traceparent() string {
+	buf := make([]byte, 0, 55)
+	buf = append(buf, "00-"...)
+	buf = appendHex(buf, tc.TraceID[:])
+	buf = append(buf, '-')
+	buf = appendHex(buf, tc.SpanID[:])
+	if tc.Sampled {
+		buf = append(buf, "-01"...)
+	} else {
+		buf = append(buf, "-00"...)
+	}
+	return string(buf)
+}
+
+// appendHex appends b to buf in lowercase hex.
+func appendHex(buf, b []byte) []byte {
+	for _, c := range b {
+		buf = append(buf, hex[c>>4], hex[c&0xf])
+	}
+	return buf
+}
+
+// traceOutputter is implemented by the Loggers of this package, to write the
+// trace context of a ReqLogger.
+type traceOutputter interface {
+	outputTrace(lvl Level, calldepth int, reqID, traceID, spanID, s string) error
+}
+
+// outputTrace writes the log with the trace ids to l. If l doesn't implement
+// traceOutputter, the ids are written as fields.
+func outputTrace(l Logger, lvl Level, calldepth int, reqID, traceID, spanID, s string) error {
+	if traceID == "" {
+		return l.Output(lvl, calldepth+1, reqID, s)
+	}
+	if to, ok := l.(traceOutputter); ok {
+		return to.outputTrace(lvl, calldepth+1, reqID, traceID, spanID, s)
+	}
+	return l.With("trace_id", traceID, "span_id", spanID).Output(lvl, calldepth+1, reqID, s)
+}
+
+// ParseTraceparent parses the value of the traceparent header.
+// The versions after 00 are accepted as long as they start with the fields of version 00.
+func ParseTraceparent(s string) (TraceContext, error) {
+	var tc TraceContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return tc, fmt.Errorf("xlog: invalid traceparent %q", s)
+	}
+	var version, flags [1]byte
+	if !decodeLowerHex(version[:], s[:2]) || version[0] == 0xff ||
+		(version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return tc, fmt.Errorf("xlog: invalid traceparent version %q", s)
+	}
+	if !decodeLowerHex(tc.TraceID[:], s[3:35]) || !tc.TraceID.IsValid() {
+		return tc, fmt.Errorf("xlog: invalid trace id in traceparent %q", s)
+	}
+	if !decodeLowerHex(tc.SpanID[:], s[36:52]) || !tc.SpanID.IsValid() {
+		return tc, fmt.Errorf("xlog: invalid parent id in traceparent %q", s)
+	}
+	if !decodeLowerHex(flags[:], s[53:55]) {
+		return tc, fmt.Errorf("xlog: invalid trace flags in traceparent %q", s)
+	}
+	tc.Sampled = flags[0]&1 == 1
+	return tc, nil
+}
+
+// decodeLowerHex decodes s to dst, it reports false if s is not lowercase hex.
+func decodeLowerHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) {
+		return false
+	}
+	for i := range dst {
+		hi, ok1 := fromLowerHex(s[2*i])
+		lo, ok2 := fromLowerHex(s[2*i+1])
+		if !ok1 || !ok2 {
+			return false
+		}
+		dst[i] = hi<<4 | lo
+	}
+	return true
+}
+
+func fromLowerHex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
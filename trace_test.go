@@ -0,0 +1,139 @@
+package xlog
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	const s = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tc, err := ParseTraceparent(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID.String() != "00f067aa0ba902b7" || !tc.Sampled {
+		t.Fatalf("unexpected %+v", tc)
+	}
+	if got := tc.Traceparent(); got != s {
+		t.Fatalf("expect %q, got %q", s, got)
+	}
+
+	// a future version with more fields.
+	if tc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02-xyz"); err != nil || tc.Sampled {
+		t.Fatalf("unexpected %+v, %v", tc, err)
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g",
+		"00_4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("%q should be invalid", s)
+		}
+	}
+}
+
+func TestTraceContextNewChild(t *testing.T) {
+	if (TraceContext{}).IsValid() {
+		t.Fatal("the zero TraceContext should be invalid")
+	}
+	root := NewTraceContext(true)
+	child := root.NewChild()
+	if !root.IsValid() || !child.IsValid() || child.TraceID != root.TraceID || child.SpanID == root.SpanID || !child.Sampled {
+		t.Fatalf("root %+v, child %+v", root, child)
+	}
+	if tc := (TraceContext{}).NewChild(); !tc.IsValid() {
+		t.Fatal("NewChild of the zero TraceContext should start a new trace")
+	}
+}
+
+func TestReqLoggerTrace(t *testing.T) {
+	tc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	for _, c := range []struct {
+		enc    Encoder
+		expect string
+	}{
+		{TextEncoder{}, "[INFO][id][4bf92f3577b34da6a3ce929d0e0e4736/00f067aa0ba902b7]hello k=v\n"},
+		{JSONEncoder{}, `{"level":"info","reqid":"id","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","msg":"hello","k":"v"}` + "\n"},
+		{LogfmtEncoder{}, "level=info reqid=id trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 msg=hello k=v\n"},
+	} {
+		b := new(bytes.Buffer)
+		rl := NewReqLogger(NewWithWriter(b, &Config{Encoder: c.enc}), ReqConfig{ReqID: "id", Trace: tc})
+		rl.With("k", "v").Info("hello")
+		if b.String() != c.expect {
+			t.Errorf("%T: expect %q, got %q", c.enc, c.expect, b.String())
+		}
+	}
+}
+
+func TestReqLoggerStartSpan(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Flag: Lshortfile})
+	rl := NewReqLogger(l, ReqConfig{ReqID: "id", Level: LevelDebug})
+	rl.Info("no trace")
+	span := rl.StartSpan()
+	child := NewSampler(span, SamplerConfig{}).(ReqLogger).StartSpan()
+	span.Debug("span")
+	child.Debug("child")
+
+	root, sub := span.RequestConfig().Trace, child.RequestConfig().Trace
+	if !root.IsValid() || sub.TraceID != root.TraceID || sub.SpanID == root.SpanID {
+		t.Fatalf("span %+v, child %+v", root, sub)
+	}
+	expect := "[INFO][id]trace_test.go:84: no trace\n" +
+		"[DEBU][id][" + root.TraceID.String() + "/" + root.SpanID.String() + "]trace_test.go:87: span\n" +
+		"[DEBU][id][" + sub.TraceID.String() + "/" + sub.SpanID.String() + "]trace_test.go:88: child\n"
+	if b.String() != expect {
+		t.Fatalf("expect %q, got %q", expect, b.String())
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	var server ReqLogger
+	srv := httptest.NewServer(NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server = FromContextSafe(r.Context())
+	}), MiddlewareConfig{Logger: NewWithWriter(new(bytes.Buffer), nil)}))
+	defer srv.Close()
+
+	b := new(bytes.Buffer)
+	rl := NewReqLogger(NewWithWriter(b, nil), ReqConfig{ReqID: "id", Level: LevelDebug, Trace: NewTraceContext(true)})
+	client := &http.Client{Transport: NewTransport(nil, TransportConfig{Log: true})}
+	req, _ := http.NewRequestWithContext(NewContext(context.Background(), rl), http.MethodGet, srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	clientTC, got := rl.RequestConfig().Trace, server.RequestConfig().Trace
+	if got.TraceID != clientTC.TraceID || got.SpanID == clientTC.SpanID || !got.Sampled {
+		t.Fatalf("client %+v, server %+v", clientTC, got)
+	}
+	// the outgoing request is logged in a child span.
+	prefix := "[DEBU][id][" + clientTC.TraceID.String() + "/"
+	if !strings.HasPrefix(b.String(), prefix) || strings.Contains(b.String(), clientTC.SpanID.String()) {
+		t.Fatalf("got %q", b.String())
+	}
+}
+
+func TestMiddlewareStartTrace(t *testing.T) {
+	var tc TraceContext
+	h := NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tc = FromContextSafe(r.Context()).RequestConfig().Trace
+	}), MiddlewareConfig{Logger: NewWithWriter(new(bytes.Buffer), nil), StartTrace: true})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !tc.IsValid() || !tc.Sampled {
+		t.Fatalf("unexpected %+v", tc)
+	}
+}
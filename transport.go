@@ -0,0 +1,89 @@
+package xlog
+
+import (
+	"net/http"
+	"time"
+)
+
+// TransportConfig is the config of NewTransport.
+type TransportConfig struct {
+	// Header is the request header which the request id is written to, HeaderReqID by default.
+	Header string
+	// Log writes a debug log through the ReqLogger after each request:
+	//
+	//	[DEBU][reqID]http request method=GET url=http://host/a status=200 duration=1.2ms
+	//
+	// The failed requests are always written as warning logs with the error.
+	Log bool
+}
+
+// NewTransport returns an http.RoundTripper which writes the request id of the
+// ReqLogger in the context of the request to the request header, so that it can
+// be read by NewMiddleware of the next service. If the ReqLogger has a valid
+// TraceContext, the request is sent in a child span by the traceparent header,
+// and the logs of the request are written with the child span:
+//
+//	client := &http.Client{Transport: xlog.NewTransport(nil, xlog.TransportConfig{})}
+//	req, _ := http.NewRequestWithContext(xlog.NewContext(ctx, rl), "GET", url, nil)
+//	resp, err := client.Do(req)
+//
+// The requests without a ReqLogger are sent as they are. If base is nil,
+// http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, c TransportConfig) http.RoundTripper {
+	if c.Header == "" {
+		c.Header = HeaderReqID
+	}
+	return &transport{base: base, c: c}
+}
+
+type transport struct {
+	base http.RoundTripper
+	c    TransportConfig
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	rl, ok := FromContext(req.Context())
+	if !ok {
+		return base.RoundTrip(req)
+	}
+	rc := rl.RequestConfig()
+	reqID := rc.ReqID
+	if reqID != "" || rc.Trace.IsValid() {
+		// A RoundTripper should not modify the request.
+		req = req.Clone(req.Context())
+	}
+	if reqID != "" {
+		req.Header.Set(t.c.Header, reqID)
+	}
+	if rc.Trace.IsValid() {
+		rl = rl.StartSpan()
+		req.Header.Set(HeaderTraceparent, rl.RequestConfig().Trace.Traceparent())
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		if rl.GetLevel() <= LevelWarn {
+			_ = rl.With(
+				"method", req.Method,
+				"url", req.URL.Redacted(),
+				"duration", time.Since(start),
+				"err", err,
+			).Output(LevelWarn, 1, reqID, "http request failed")
+		}
+		return resp, err
+	}
+	if t.c.Log && rl.GetLevel() <= LevelDebug {
+		_ = rl.With(
+			"method", req.Method,
+			"url", req.URL.Redacted(),
+			"status", resp.StatusCode,
+			"duration", time.Since(start),
+		).Output(LevelDebug, 1, reqID, "http request")
+	}
+	return resp, err
+}
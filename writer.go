@@ -0,0 +1,144 @@
+package xlog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"strings"
+)
+
+// Writer returns an io.Writer which writes each line of the input to l at lvl,
+// with the caller of Write as the caller of the log. If l is nil, the default
+// logger is used. If l is a ReqLogger, its request id is written too.
+// The lines are not written if lvl is disabled by l, unless lvl is LevelPrint.
+//
+// Each Write is split on newlines, and a trailing line without a newline is
+// written as a whole line, so a line should not be split into several Writes.
+func Writer(l Logger, lvl Level) io.Writer {
+	return newLevelWriter(l, lvl, 0)
+}
+
+// StdLogger returns a *log.Logger which writes to l at lvl, for the APIs
+// requiring a *log.Logger, e.g. http.Server.ErrorLog:
+//
+//	srv := &http.Server{ErrorLog: xlog.StdLogger(l, xlog.LevelError)}
+//
+// The prefix and flags of the *log.Logger are empty, the ones of l are used.
+// The caller of the log is the caller of the methods of the *log.Logger.
+func StdLogger(l Logger, lvl Level) *log.Logger {
+	// log.(*Logger).output + log.(*Logger).Print
+	return log.New(newLevelWriter(l, lvl, 2), "", 0)
+}
+
+// RedirectStdLog redirects the output of the standard log package to l, and
+// returns a function to restore it. If l is nil, the default logger is used.
+//
+// The level of each line is inferred from its leading tag like "[ERROR]",
+// "warning:" or "panic:", case-insensitively, and it is LevelInfo if there is
+// none. The tag is kept in the message, and the Fatal and Panic tags only
+// change the level of the log, they don't exit or panic.
+func RedirectStdLog(l Logger) func() {
+	w := newLevelWriter(l, LevelInfo, 2)
+	w.infer = true
+	return redirectStdLog(w)
+}
+
+// RedirectStdLogAt is the same as RedirectStdLog, except the lines are written at lvl.
+func RedirectStdLogAt(l Logger, lvl Level) func() {
+	return redirectStdLog(newLevelWriter(l, lvl, 2))
+}
+
+func redirectStdLog(w io.Writer) func() {
+	out, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+	log.SetOutput(w)
+	log.SetFlags(0)
+	log.SetPrefix("")
+	return func() {
+		log.SetOutput(out)
+		log.SetFlags(flags)
+		log.SetPrefix(prefix)
+	}
+}
+
+// levelTags are the leading tags of the lines from which the level is inferred.
+var levelTags = []struct {
+	tag string
+	lvl Level
+}{
+	{"[debug]", LevelDebug},
+	{"debug:", LevelDebug},
+	{"[info]", LevelInfo},
+	{"info:", LevelInfo},
+	{"[warn]", LevelWarn},
+	{"[warning]", LevelWarn},
+	{"warn:", LevelWarn},
+	{"warning:", LevelWarn},
+	{"[error]", LevelError},
+	{"[err]", LevelError},
+	{"error:", LevelError},
+	{"[fatal]", LevelFatal},
+	{"fatal:", LevelFatal},
+	{"[panic]", LevelPanic},
+	{"panic:", LevelPanic},
+}
+
+// inferLevel returns the level of the leading tag of line, or def if there is none.
+func inferLevel(line []byte, def Level) Level {
+	line = bytes.TrimLeft(line, " \t")
+	for _, t := range levelTags {
+		if len(line) >= len(t.tag) && strings.EqualFold(string(line[:len(t.tag)]), t.tag) {
+			return t.lvl
+		}
+	}
+	return def
+}
+
+// levelWriter is the io.Writer returned by Writer.
+type levelWriter struct {
+	l         Logger
+	lvl       Level
+	infer     bool // infer the level of each line, lvl is the default.
+	reqID     string
+	calldepth int
+}
+
+// newLevelWriter creates a levelWriter, skip is the number of the frames
+// between Write and the caller of the log.
+func newLevelWriter(l Logger, lvl Level, skip int) *levelWriter {
+	// Output() + Write()
+	calldepth := 2 + skip
+	if l == nil {
+		l = defaultLogger
+		// defaultLogger adds one for the package-level functions, which is not there.
+		calldepth--
+	}
+	w := &levelWriter{l: l, lvl: lvl, calldepth: calldepth}
+	if rl, ok := l.(ReqLogger); ok {
+		w.reqID = rl.RequestConfig().ReqID
+	}
+	return w
+}
+
+// Write writes each line of p as a log, it always returns len(p) and the first error of Output.
+func (w *levelWriter) Write(p []byte) (int, error) {
+	var err error
+	for b := p; len(b) > 0; {
+		line := b
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			line, b = b[:i], b[i+1:]
+		} else {
+			b = nil
+		}
+		lvl := w.lvl
+		if w.infer {
+			lvl = inferLevel(line, lvl)
+		}
+		if lvl != LevelPrint && w.l.GetLevel() > lvl {
+			continue
+		}
+		if err2 := w.l.Output(lvl, w.calldepth, w.reqID, string(line)); err == nil {
+			err = err2
+		}
+	}
+	return len(p), err
+}
@@ -0,0 +1,93 @@
+package xlog
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Flag: Lshortfile, Level: LevelInfo})
+	w := Writer(l, LevelWarn)
+	n, err := w.Write([]byte("a\nb\n\nc"))
+	if n != 6 || err != nil {
+		t.Fatalf("n %d, err %v", n, err)
+	}
+	_, _ = Writer(l, LevelDebug).Write([]byte("disabled\n"))
+	_, _ = Writer(NewReqLogger(l, ReqConfig{ReqID: "id"}), LevelPrint).Write([]byte("print\n"))
+
+	expect := "[WARN]writer_test.go:15: a\n" +
+		"[WARN]writer_test.go:15: b\n" +
+		"[WARN]writer_test.go:15: \n" +
+		"[WARN]writer_test.go:15: c\n" +
+		"[id]writer_test.go:20: print\n"
+	if got := b.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Prefix: "P ", Flag: Lshortfile})
+	sl := StdLogger(NewReqLogger(l, ReqConfig{ReqID: "id"}), LevelError)
+	sl.Println("println")
+	sl.Printf("printf %d", 1)
+	sl.Print("multi\nline")
+
+	expect := "P [ERRO][id]writer_test.go:36: println\n" +
+		"P [ERRO][id]writer_test.go:37: printf 1\n" +
+		"P [ERRO][id]writer_test.go:38: multi\n" +
+		"P [ERRO][id]writer_test.go:38: line\n"
+	if got := b.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+}
+
+func TestStdLoggerDefault(t *testing.T) {
+	b := new(bytes.Buffer)
+	defer func(l Logger) { defaultLogger = l }(defaultLogger)
+	defaultLogger = NewWithWriter(b, &Config{Flag: Lshortfile, BaseCalldepth: 1})
+	StdLogger(nil, LevelInfo).Print("default")
+	if got := b.String(); got != "[INFO]writer_test.go:53: default\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRedirectStdLog(t *testing.T) {
+	b := new(bytes.Buffer)
+	l := NewWithWriter(b, &Config{Flag: Lshortfile, Level: LevelInfo})
+	restore := RedirectStdLog(l)
+	log.Printf("[ERROR] failed: %d", 1)
+	log.Print("  Warning: deprecated")
+	log.Println("[debug] hidden")
+	log.Println("plain")
+	log.Print("panic: boom\ngoroutine 1 [running]:")
+	restore()
+	log.SetOutput(io.Discard)
+	log.Print("not redirected")
+	restore()
+
+	expect := "[ERRO]writer_test.go:63: [ERROR] failed: 1\n" +
+		"[WARN]writer_test.go:64:   Warning: deprecated\n" +
+		"[INFO]writer_test.go:66: plain\n" +
+		"[PANI]writer_test.go:67: panic: boom\n" +
+		"[INFO]writer_test.go:67: goroutine 1 [running]:\n"
+	if got := b.String(); got != expect {
+		t.Fatalf("expect %q, got %q", expect, got)
+	}
+	if log.Writer() != os.Stderr || log.Flags() != log.LstdFlags {
+		t.Fatal("the standard log package is not restored")
+	}
+}
+
+func TestRedirectStdLogAt(t *testing.T) {
+	b := new(bytes.Buffer)
+	defer RedirectStdLogAt(NewWithWriter(b, nil), LevelWarn)()
+	log.Print("[ERROR] not inferred")
+	if got := b.String(); got != "[WARN][ERROR] not inferred\n" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -25,6 +25,25 @@ type Logger interface {
 	// return the copy of Config.
 	CopyConfig() Config
 
+	// GetLevel returns the current level.
+	GetLevel() Level
+	// SetLevel changes the level, it is safe to be called concurrently.
+	SetLevel(lvl Level)
+
+	// With returns a Logger that carries the key/value pairs in keyvals, which
+	// are printed after the message of every log. The keys should be strings.
+	// The returned Logger shares the writer of the receiver.
+	// If the receiver is a ReqLogger, the returned Logger is a ReqLogger too.
+	With(keyvals ...interface{}) Logger
+	// WithFields is the same as With, except the fields are given as a map.
+	WithFields(fields Fields) Logger
+
+	// Sync flushes the logs buffered by the writer.
+	Sync() error
+	// Close flushes the logs and closes the writer.
+	// The Fatal functions call it before the program exits.
+	Close() error
+
 	// Print is not affected by `Log Level`。 It will print the message regardless of `Log Level`.
 	Print(v ...interface{})
 	Printf(format string, v ...interface{})
@@ -65,5 +84,8 @@ func NewWithWriter(w io.Writer, c *Config) Logger {
 	if w == nil {
 		w = os.Stderr
 	}
-	return newLogger(w, c)
+	if c == nil {
+		c = &Config{}
+	}
+	return newLogger(c, []Sink{{Writer: w, Encoder: c.Encoder, ForceColors: c.ForceColors}})
 }